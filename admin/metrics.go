@@ -0,0 +1,52 @@
+package admin
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics holds the Prometheus collectors exposed at GET /metrics. It also
+// implements client.Metrics so the Graph client can record directly against it.
+type Metrics struct {
+	EventsCollected *prometheus.CounterVec
+	HttpRetries     prometheus.Counter
+	RateLimitHits   prometheus.Counter
+	AuthRefreshes   prometheus.Counter
+	RequestLatency  prometheus.Histogram
+}
+
+func newMetrics() *Metrics {
+	return &Metrics{
+		EventsCollected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "microsoft_graph_collector_events_collected_total",
+			Help: "Total events collected, by collector",
+		}, []string{"collector"}),
+		HttpRetries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "microsoft_graph_collector_http_retries_total",
+			Help: "Total HTTP retries issued against the Graph API",
+		}),
+		RateLimitHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "microsoft_graph_collector_rate_limit_hits_total",
+			Help: "Total 429 responses received from the Graph API",
+		}),
+		AuthRefreshes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "microsoft_graph_collector_auth_refreshes_total",
+			Help: "Total access token refreshes",
+		}),
+		RequestLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "microsoft_graph_collector_request_duration_seconds",
+			Help:    "Graph API request latency in seconds",
+			Buckets: prometheus.DefBuckets,
+		}),
+	}
+}
+
+func (m *Metrics) IncHttpRetries()   { m.HttpRetries.Inc() }
+func (m *Metrics) IncRateLimitHits() { m.RateLimitHits.Inc() }
+func (m *Metrics) IncAuthRefreshes() { m.AuthRefreshes.Inc() }
+
+func (m *Metrics) ObserveRequestLatency(seconds float64) {
+	m.RequestLatency.Observe(seconds)
+}
+
+// IncEventsCollected records events collected for a single collector
+func (m *Metrics) IncEventsCollected(collector string, count int) {
+	m.EventsCollected.WithLabelValues(collector).Add(float64(count))
+}
@@ -0,0 +1,227 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ErrConflict is returned by the reload hook when the caller's fingerprint no
+// longer matches the current configuration - POST /reload surfaces this as
+// 409 Conflict so the caller knows to re-fetch before retrying.
+var ErrConflict = errors.New("conflict")
+
+// Server is the opt-in admin HTTP server exposing health, status, runtime
+// control and metrics endpoints for a running collector process.
+type Server struct {
+	listenAddr  string
+	token       string
+	authExpiry  func() time.Time
+	fingerprint func() string
+	reload      func(fingerprint string) error
+
+	mu         sync.RWMutex
+	collectors map[string]*CollectorStatus
+	handles    map[string]*CollectorHandle
+	routes     map[string]http.HandlerFunc
+
+	metrics  *Metrics
+	registry *prometheus.Registry
+}
+
+// NewServer builds an admin server bound to listenAddr. Mutating endpoints
+// require the X-Admin-Token header to match token, unless token is empty.
+// authExpiry reports the shared Graph client's current access token expiry
+// for /status. fingerprint reports the structured config file's current
+// content fingerprint for /status (empty if there's no config file), and
+// reload is invoked by POST /reload with the fingerprint the caller read
+// /status as - a caller can only ever learn a valid fingerprint by reading
+// it from there first.
+func NewServer(listenAddr, token string, authExpiry func() time.Time, fingerprint func() string, reload func(fingerprint string) error) *Server {
+	metrics := newMetrics()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(metrics.EventsCollected, metrics.HttpRetries, metrics.RateLimitHits, metrics.AuthRefreshes, metrics.RequestLatency)
+
+	return &Server{
+		listenAddr:  listenAddr,
+		token:       token,
+		authExpiry:  authExpiry,
+		fingerprint: fingerprint,
+		reload:      reload,
+		collectors:  make(map[string]*CollectorStatus),
+		handles:     make(map[string]*CollectorHandle),
+		routes:      make(map[string]http.HandlerFunc),
+		metrics:     metrics,
+		registry:    registry,
+	}
+}
+
+// RegisterHandler mounts an additional route on the admin server's mux before
+// it starts - e.g. the subscription webhook receiver reusing this server
+// instead of standing up a separate --webhook-listen port.
+func (s *Server) RegisterHandler(pattern string, handler http.HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes[pattern] = handler
+}
+
+// Metrics exposes the Prometheus collectors so client code (the Graph
+// client's retry/auth logic) can record against them directly.
+func (s *Server) Metrics() *Metrics {
+	return s.metrics
+}
+
+// RegisterCollector creates the control channels and status entry for a
+// collector and returns the handle its poll loop uses to react to admin calls.
+func (s *Server) RegisterCollector(name string) *CollectorHandle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.collectors[name] = &CollectorStatus{Name: name}
+	handle := &CollectorHandle{
+		Pause:     make(chan struct{}, 1),
+		Resume:    make(chan struct{}, 1),
+		ForcePoll: make(chan struct{}, 1),
+		name:      name,
+		server:    s,
+	}
+	s.handles[name] = handle
+
+	return handle
+}
+
+// Start runs the admin HTTP server until the process exits or it fails to
+// bind. It's meant to be launched in its own goroutine.
+func (s *Server) Start() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleHealthz)
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/poll", s.protect(s.handlePoll))
+	mux.HandleFunc("/pause", s.protect(s.handlePause))
+	mux.HandleFunc("/resume", s.protect(s.handleResume))
+	mux.HandleFunc("/reload", s.protect(s.handleReload))
+	mux.Handle("/metrics", promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{}))
+
+	s.mu.RLock()
+	for pattern, handler := range s.routes {
+		mux.HandleFunc(pattern, handler)
+	}
+	s.mu.RUnlock()
+
+	return http.ListenAndServe(s.listenAddr, mux)
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+type statusResponse struct {
+	AuthTokenExpiry   time.Time                   `json:"auth_token_expiry"`
+	ConfigFingerprint string                      `json:"config_fingerprint,omitempty"`
+	Collectors        map[string]*CollectorStatus `json:"collectors"`
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	s.mu.RLock()
+	collectors := make(map[string]*CollectorStatus, len(s.collectors))
+	for name, status := range s.collectors {
+		snapshot := *status
+		collectors[name] = &snapshot
+	}
+	s.mu.RUnlock()
+
+	resp := statusResponse{
+		AuthTokenExpiry:   s.authExpiry(),
+		ConfigFingerprint: s.fingerprint(),
+		Collectors:        collectors,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// protect requires the shared admin token on mutating endpoints
+func (s *Server) protect(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" && r.Header.Get("X-Admin-Token") != s.token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handlePoll forces an immediate collection. With no ?collector= query param
+// every registered collector is signalled.
+func (s *Server) handlePoll(w http.ResponseWriter, r *http.Request) {
+	s.signalHandles(w, r, func(h *CollectorHandle) { trySignal(h.ForcePoll) })
+}
+
+// handlePause gates a collector's ticker until resumed via /resume
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	s.signalHandles(w, r, func(h *CollectorHandle) { trySignal(h.Pause) })
+}
+
+// handleResume releases a collector paused via /pause
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	s.signalHandles(w, r, func(h *CollectorHandle) { trySignal(h.Resume) })
+}
+
+// signalHandles dispatches action to either the named ?collector= handle or,
+// if none was given, every registered collector.
+func (s *Server) signalHandles(w http.ResponseWriter, r *http.Request, action func(*CollectorHandle)) {
+	name := r.URL.Query().Get("collector")
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if name != "" {
+		handle, ok := s.handles[name]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown collector %q", name), http.StatusNotFound)
+			return
+		}
+		action(handle)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	for _, handle := range s.handles {
+		action(handle)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleReload re-validates (and, with a structured config file, re-reads)
+// the running configuration. ?fingerprint= must match the config_fingerprint
+// last read from GET /status, so two concurrent edits can't silently clobber
+// each other - a stale fingerprint gets 409 Conflict instead of applying.
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if err := s.reload(r.URL.Query().Get("fingerprint")); err != nil {
+		if errors.Is(err, ErrConflict) {
+			http.Error(w, "config changed since fingerprint was read", http.StatusConflict)
+			return
+		}
+		http.Error(w, fmt.Sprintf("reload failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]bool{"reloaded": true})
+}
+
+// trySignal delivers a control signal without blocking if one is already pending
+func trySignal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
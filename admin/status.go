@@ -0,0 +1,54 @@
+package admin
+
+import "time"
+
+// CollectorStatus is the point-in-time snapshot of a single collector's poll
+// state, returned by GET /status.
+type CollectorStatus struct {
+	Name           string    `json:"name"`
+	Paused         bool      `json:"paused"`
+	LastPollTime   time.Time `json:"last_poll_time,omitempty"`
+	LastEventCount int       `json:"last_event_count"`
+	NextPollTime   time.Time `json:"next_poll_time,omitempty"`
+	LastError      string    `json:"last_error,omitempty"`
+}
+
+// CollectorHandle is a single collector's connection to the admin server: the
+// channels its poll loop selects on for external control, and the status
+// updates it reports back after every poll attempt.
+type CollectorHandle struct {
+	Pause     chan struct{}
+	Resume    chan struct{}
+	ForcePoll chan struct{}
+
+	name   string
+	server *Server
+}
+
+// SetPaused updates the paused flag shown in /status
+func (h *CollectorHandle) SetPaused(paused bool) {
+	h.server.mu.Lock()
+	defer h.server.mu.Unlock()
+	h.server.collectors[h.name].Paused = paused
+}
+
+// RecordPoll updates the collector's status after a poll attempt and, on
+// success, bumps the events-collected metric. Pass a non-nil err to record a
+// failed attempt instead.
+func (h *CollectorHandle) RecordPoll(eventCount int, polledAt time.Time, next time.Time, err error) {
+	h.server.mu.Lock()
+	defer h.server.mu.Unlock()
+
+	status := h.server.collectors[h.name]
+	status.LastPollTime = polledAt
+	status.NextPollTime = next
+
+	if err != nil {
+		status.LastError = err.Error()
+		return
+	}
+
+	status.LastError = ""
+	status.LastEventCount = eventCount
+	h.server.metrics.IncEventsCollected(h.name, eventCount)
+}
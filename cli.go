@@ -2,15 +2,22 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"github.com/rfizzle/collector-helpers/config"
 	"github.com/rfizzle/collector-helpers/outputs"
 	"github.com/rfizzle/collector-helpers/state"
+	"github.com/rfizzle/microsoft-graph-collector/client"
+	"github.com/rfizzle/microsoft-graph-collector/configfile"
 	flag "github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"strings"
 )
 
-func setupCliFlags() error {
+// setupCliFlags parses CLI flags/env vars and, if --config was given, layers
+// a structured config file underneath them as defaults - an explicitly set
+// flag or env var always wins over the file. It returns the loaded config
+// handler (nil unless --config was set) so callers can watch it for changes.
+func setupCliFlags() (*configfile.ConfigHandler, error) {
 	viper.SetEnvPrefix("MICROSOFT_GRAPH_COLLECTOR")
 	viper.AutomaticEnv()
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
@@ -20,24 +27,247 @@ func setupCliFlags() error {
 	flag.String("tenant-id", "", "tenant id")
 	flag.String("client-id", "", "client id")
 	flag.String("client-secret", "", "client secret")
+	flag.String("auth-mode", "secret", "authentication mode: secret, certificate, managed-identity")
+	flag.String("cert-path", "", "path to the PEM private key used for certificate auth")
+	flag.String("cert-thumbprint", "", "hex-encoded thumbprint of the certificate used for certificate auth")
+	flag.String("managed-identity-client-id", "", "client id of the user-assigned managed identity (blank uses the system-assigned identity)")
+	flag.StringSlice("collectors", []string{"alerts"}, "comma separated list of collectors to enable (alerts, directoryAudits, signIns, messages)")
+	flag.Int("max-retries", client.DefaultRetryConfig().MaxRetries, "maximum retry attempts for transient Graph API errors (0 disables retries)")
+	flag.Int("initial-backoff-ms", client.DefaultRetryConfig().InitialBackoffMs, "initial backoff in milliseconds before the first retry")
+	flag.Int("max-backoff-ms", client.DefaultRetryConfig().MaxBackoffMs, "maximum backoff in milliseconds between retries")
+	flag.String("admin-listen", "", "bind address for the admin HTTP API (e.g. 127.0.0.1:8081); empty disables it")
+	flag.String("admin-token", "", "shared token required via X-Admin-Token on mutating admin endpoints")
+	flag.String("mode", "poll", "collector mode: poll or subscription (Graph change notifications)")
+	flag.String("notification-url", "", "public HTTPS URL Graph will call with change notifications (subscription mode)")
+	flag.String("webhook-listen", "", "bind address for the notification receiver in subscription mode; empty reuses --admin-listen")
 	flag.BoolP("verbose", "v", false, "verbose logging")
 	state.InitCLIParams()
 	outputs.InitCLIParams()
 	flag.Parse()
-	err := viper.BindPFlags(flag.CommandLine)
+	if err := viper.BindPFlags(flag.CommandLine); err != nil {
+		return nil, err
+	}
 
-	if err != nil {
-		return err
+	var configHandler *configfile.ConfigHandler
+	if path := viper.GetString("config"); path != "" {
+		var err error
+		configHandler, err = configfile.Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load config file: %v", err)
+		}
+
+		applyConfigDefaults(configHandler.Config())
+
+		if err := validateResourceModes(configHandler.Config()); err != nil {
+			return nil, err
+		}
 	}
 
 	// Check config
 	if err := config.CheckConfigParams(); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Check parameters
 	if err := checkRequiredParams(); err != nil {
-		return err
+		return nil, err
+	}
+
+	return configHandler, nil
+}
+
+// applyConfigDefaults layers a loaded config file's values in as viper
+// defaults - the lowest-priority source, so an explicitly set flag or env
+// var still overrides it.
+func applyConfigDefaults(cfg configfile.Config) {
+	if cfg.TenantId != "" {
+		viper.SetDefault("tenant-id", cfg.TenantId)
+	}
+	if cfg.ClientId != "" {
+		viper.SetDefault("client-id", cfg.ClientId)
+	}
+	if cfg.Auth.Mode != "" {
+		viper.SetDefault("auth-mode", cfg.Auth.Mode)
+	}
+	if cfg.Auth.ClientSecret != "" {
+		viper.SetDefault("client-secret", cfg.Auth.ClientSecret)
+	}
+	if cfg.Auth.CertPath != "" {
+		viper.SetDefault("cert-path", cfg.Auth.CertPath)
+	}
+	if cfg.Auth.CertThumbprint != "" {
+		viper.SetDefault("cert-thumbprint", cfg.Auth.CertThumbprint)
+	}
+	if cfg.Auth.ManagedIdentityClientId != "" {
+		viper.SetDefault("managed-identity-client-id", cfg.Auth.ManagedIdentityClientId)
+	}
+	if cfg.Retry.MaxRetries != 0 {
+		viper.SetDefault("max-retries", cfg.Retry.MaxRetries)
+	}
+	if cfg.Retry.InitialBackoffMs != 0 {
+		viper.SetDefault("initial-backoff-ms", cfg.Retry.InitialBackoffMs)
+	}
+	if cfg.Retry.MaxBackoffMs != 0 {
+		viper.SetDefault("max-backoff-ms", cfg.Retry.MaxBackoffMs)
+	}
+	if cfg.NotificationUrl != "" {
+		viper.SetDefault("notification-url", cfg.NotificationUrl)
+	}
+	if cfg.AdminListen != "" {
+		viper.SetDefault("admin-listen", cfg.AdminListen)
+	}
+	if cfg.WebhookListen != "" {
+		viper.SetDefault("webhook-listen", cfg.WebhookListen)
+	}
+	if len(cfg.Resources) > 0 {
+		names := make([]string, len(cfg.Resources))
+		for i, r := range cfg.Resources {
+			names[i] = r.Name
+		}
+		viper.SetDefault("collectors", names)
+	}
+
+	if cfg.State.Path != "" {
+		viper.SetDefault("state-path", cfg.State.Path)
+	}
+
+	applyOutputsConfigDefaults(cfg.Outputs)
+}
+
+// applyOutputsConfigDefaults layers a loaded config file's outputs section in
+// as viper defaults, the same way applyConfigDefaults does for everything else.
+func applyOutputsConfigDefaults(cfg configfile.OutputsSection) {
+	if cfg.File.Enabled {
+		viper.SetDefault("file", true)
+	}
+	if cfg.File.Path != "" {
+		viper.SetDefault("file-path", cfg.File.Path)
+	}
+	if cfg.File.Rotate {
+		viper.SetDefault("file-rotate", true)
+	}
+
+	if cfg.PubSub.Enabled {
+		viper.SetDefault("pubsub", true)
+	}
+	if cfg.PubSub.Project != "" {
+		viper.SetDefault("pubsub-project", cfg.PubSub.Project)
+	}
+	if cfg.PubSub.Topic != "" {
+		viper.SetDefault("pubsub-topic", cfg.PubSub.Topic)
+	}
+	if cfg.PubSub.Credentials != "" {
+		viper.SetDefault("pubsub-credentials", cfg.PubSub.Credentials)
+	}
+
+	if cfg.GCS.Enabled {
+		viper.SetDefault("gcs", true)
+	}
+	if cfg.GCS.Bucket != "" {
+		viper.SetDefault("gcs-bucket", cfg.GCS.Bucket)
+	}
+	if cfg.GCS.Path != "" {
+		viper.SetDefault("gcs-path", cfg.GCS.Path)
+	}
+	if cfg.GCS.Composite {
+		viper.SetDefault("gcs-composite", true)
+	}
+	if cfg.GCS.Credentials != "" {
+		viper.SetDefault("gcs-credentials", cfg.GCS.Credentials)
+	}
+
+	if cfg.S3.Enabled {
+		viper.SetDefault("s3", true)
+	}
+	if cfg.S3.Region != "" {
+		viper.SetDefault("s3-region", cfg.S3.Region)
+	}
+	if cfg.S3.Bucket != "" {
+		viper.SetDefault("s3-bucket", cfg.S3.Bucket)
+	}
+	if cfg.S3.Path != "" {
+		viper.SetDefault("s3-path", cfg.S3.Path)
+	}
+	if cfg.S3.AccessKeyId != "" {
+		viper.SetDefault("s3-access-key-id", cfg.S3.AccessKeyId)
+	}
+	if cfg.S3.SecretKey != "" {
+		viper.SetDefault("s3-secret-key", cfg.S3.SecretKey)
+	}
+	if cfg.S3.StorageClass != "" {
+		viper.SetDefault("s3-storage-class", cfg.S3.StorageClass)
+	}
+
+	if cfg.Stackdriver.Enabled {
+		viper.SetDefault("stackdriver", true)
+	}
+	if cfg.Stackdriver.Project != "" {
+		viper.SetDefault("stackdriver-project", cfg.Stackdriver.Project)
+	}
+	if cfg.Stackdriver.LogName != "" {
+		viper.SetDefault("stackdriver-log-name", cfg.Stackdriver.LogName)
+	}
+	if cfg.Stackdriver.Credentials != "" {
+		viper.SetDefault("stackdriver-credentials", cfg.Stackdriver.Credentials)
+	}
+
+	if cfg.HTTP.Enabled {
+		viper.SetDefault("http", true)
+	}
+	if cfg.HTTP.Url != "" {
+		viper.SetDefault("http-url", cfg.HTTP.Url)
+	}
+	if cfg.HTTP.Auth != "" {
+		viper.SetDefault("http-auth", cfg.HTTP.Auth)
+	}
+	if cfg.HTTP.MaxItems != 0 {
+		viper.SetDefault("http-max-items", cfg.HTTP.MaxItems)
+	}
+
+	if cfg.Elastic.Enabled {
+		viper.SetDefault("elasticsearch", true)
+	}
+	if cfg.Elastic.Cloud {
+		viper.SetDefault("elastic-cloud", true)
+	}
+	if cfg.Elastic.CloudId != "" {
+		viper.SetDefault("elastic-cloud-id", cfg.Elastic.CloudId)
+	}
+	if len(cfg.Elastic.Urls) > 0 {
+		viper.SetDefault("elastic-urls", cfg.Elastic.Urls)
+	}
+	if cfg.Elastic.Index != "" {
+		viper.SetDefault("elastic-index", cfg.Elastic.Index)
+	}
+	if cfg.Elastic.ApiKey != "" {
+		viper.SetDefault("elastic-api-key", cfg.Elastic.ApiKey)
+	}
+	if cfg.Elastic.Username != "" {
+		viper.SetDefault("elastic-username", cfg.Elastic.Username)
+	}
+	if cfg.Elastic.Password != "" {
+		viper.SetDefault("elastic-password", cfg.Elastic.Password)
+	}
+	if cfg.Elastic.CaCert != "" {
+		viper.SetDefault("elastic-ca-cert", cfg.Elastic.CaCert)
+	}
+}
+
+// validateResourceModes rejects a resource requesting subscription mode
+// while --mode isn't globally "subscription" - subscribing at all also needs
+// --notification-url/--webhook-listen, which are process-wide, so a
+// per-resource request for it can only ever be silently ignored otherwise.
+func validateResourceModes(cfg configfile.Config) error {
+	for _, r := range cfg.Resources {
+		switch r.Mode {
+		case "", "poll":
+		case "subscription":
+			if viper.GetString("mode") != "subscription" {
+				return fmt.Errorf("resource %q requests mode \"subscription\" but --mode is %q", r.Name, viper.GetString("mode"))
+			}
+		default:
+			return fmt.Errorf("resource %q has unknown mode %q", r.Name, r.Mode)
+		}
 	}
 
 	return nil
@@ -52,8 +282,20 @@ func checkRequiredParams() error {
 		return errors.New("missing client id param (--client-id)")
 	}
 
-	if viper.GetString("client-secret") == "" {
-		return errors.New("missing client secret param (--client-secret)")
+	if err := checkAuthParams(); err != nil {
+		return err
+	}
+
+	if err := checkModeParams(); err != nil {
+		return err
+	}
+
+	if err := validateCollectors(viper.GetStringSlice("collectors")); err != nil {
+		return err
+	}
+
+	if viper.GetInt("max-retries") < 0 {
+		return errors.New("max retries param (--max-retries) cannot be negative")
 	}
 
 	if err := state.ValidateCLIParams(); err != nil {
@@ -66,3 +308,82 @@ func checkRequiredParams() error {
 
 	return nil
 }
+
+// checkAuthParams validates the flags required by the selected --auth-mode
+func checkAuthParams() error {
+	switch viper.GetString("auth-mode") {
+	case "secret":
+		if viper.GetString("client-secret") == "" {
+			return errors.New("missing client secret param (--client-secret)")
+		}
+	case "certificate":
+		if viper.GetString("cert-path") == "" {
+			return errors.New("missing certificate path param (--cert-path)")
+		}
+		if viper.GetString("cert-thumbprint") == "" {
+			return errors.New("missing certificate thumbprint param (--cert-thumbprint)")
+		}
+	case "managed-identity":
+		// managed-identity-client-id is optional - an empty value selects the
+		// system-assigned identity
+	default:
+		return fmt.Errorf("unknown auth mode %q (--auth-mode)", viper.GetString("auth-mode"))
+	}
+
+	return nil
+}
+
+// checkModeParams validates the flags required by the selected --mode
+func checkModeParams() error {
+	switch viper.GetString("mode") {
+	case "poll":
+	case "subscription":
+		if viper.GetString("notification-url") == "" {
+			return errors.New("missing notification url param (--notification-url) for subscription mode")
+		}
+		if viper.GetString("webhook-listen") == "" && viper.GetString("admin-listen") == "" {
+			return errors.New("subscription mode requires --webhook-listen or --admin-listen to receive notifications")
+		}
+	default:
+		return fmt.Errorf("unknown mode %q (--mode)", viper.GetString("mode"))
+	}
+
+	return nil
+}
+
+// buildAuthConfig turns the configured --auth-mode flags into a client.AuthConfig
+func buildAuthConfig() client.AuthConfig {
+	switch viper.GetString("auth-mode") {
+	case "certificate":
+		return client.CertificateAuth(viper.GetString("cert-path"), viper.GetString("cert-thumbprint"))
+	case "managed-identity":
+		return client.ManagedIdentityAuth(viper.GetString("managed-identity-client-id"))
+	default:
+		return client.SecretAuth(viper.GetString("client-secret"))
+	}
+}
+
+// buildRetryConfig applies the configured retry flags on top of the client's defaults
+func buildRetryConfig() client.RetryConfig {
+	retry := client.DefaultRetryConfig()
+	retry.MaxRetries = viper.GetInt("max-retries")
+	retry.InitialBackoffMs = viper.GetInt("initial-backoff-ms")
+	retry.MaxBackoffMs = viper.GetInt("max-backoff-ms")
+	return retry
+}
+
+// validateCollectors ensures every requested collector is one this client supports
+func validateCollectors(names []string) error {
+	if len(names) == 0 {
+		return errors.New("no collectors configured (--collectors)")
+	}
+
+	available := client.AvailableCollectors()
+	for _, name := range names {
+		if _, ok := available[name]; !ok {
+			return fmt.Errorf("unknown collector %q (--collectors)", name)
+		}
+	}
+
+	return nil
+}
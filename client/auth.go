@@ -0,0 +1,242 @@
+package client
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"io/ioutil"
+	"net/url"
+	"time"
+)
+
+const (
+	clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+	certAssertionTTL    = 10 * time.Minute
+	imdsTokenUrl        = "http://169.254.169.254/metadata/identity/oauth2/token"
+	graphResource       = "https://graph.microsoft.com"
+	// tokenRefreshSkew is how far ahead of expiry we proactively refresh the token
+	tokenRefreshSkew = 2 * time.Minute
+)
+
+// AuthMode selects how a GraphClient authenticates with Microsoft Graph.
+type AuthMode string
+
+const (
+	AuthModeSecret          AuthMode = "secret"
+	AuthModeCertificate     AuthMode = "certificate"
+	AuthModeManagedIdentity AuthMode = "managed-identity"
+)
+
+// AuthConfig configures how a GraphClient authenticates. Build one with
+// SecretAuth, CertificateAuth, or ManagedIdentityAuth.
+type AuthConfig struct {
+	Mode AuthMode
+
+	// ClientSecret is used by AuthModeSecret
+	ClientSecret string
+
+	// CertPath and CertThumbprint are used by AuthModeCertificate
+	CertPath       string
+	CertThumbprint string
+
+	// ManagedIdentityClientId is used by AuthModeManagedIdentity; empty selects
+	// the system-assigned managed identity
+	ManagedIdentityClientId string
+}
+
+// SecretAuth authenticates using a client secret (grant_type=client_credentials).
+func SecretAuth(clientSecret string) AuthConfig {
+	return AuthConfig{Mode: AuthModeSecret, ClientSecret: clientSecret}
+}
+
+// CertificateAuth authenticates using a signed JWT client assertion built from
+// a PEM private key and the certificate's thumbprint.
+func CertificateAuth(certPath, certThumbprint string) AuthConfig {
+	return AuthConfig{Mode: AuthModeCertificate, CertPath: certPath, CertThumbprint: certThumbprint}
+}
+
+// ManagedIdentityAuth authenticates via the Azure Instance Metadata Service.
+// An empty clientId selects the system-assigned managed identity.
+func ManagedIdentityAuth(clientId string) AuthConfig {
+	return AuthConfig{Mode: AuthModeManagedIdentity, ManagedIdentityClientId: clientId}
+}
+
+// ensureToken refreshes the access token if it's missing or close to expiry,
+// so callers never need to log in more than once per poll window. tokenMu is
+// released before logging in, since login's own request reads accessToken().
+func (graphClient *GraphClient) ensureToken() error {
+	graphClient.tokenMu.Lock()
+	valid := graphClient.AccessToken != "" && time.Now().Before(graphClient.tokenExpiry.Add(-tokenRefreshSkew))
+	graphClient.tokenMu.Unlock()
+
+	if valid {
+		return nil
+	}
+
+	return graphClient.login()
+}
+
+// login will get a JWT with the correct grant type for collecting logs
+func (graphClient *GraphClient) login() error {
+	switch graphClient.auth.Mode {
+	case AuthModeCertificate:
+		return graphClient.loginWithCertificate()
+	case AuthModeManagedIdentity:
+		return graphClient.loginWithManagedIdentity()
+	default:
+		return graphClient.loginWithSecret()
+	}
+}
+
+// loginWithSecret authenticates using grant_type=client_credentials and a client secret
+func (graphClient *GraphClient) loginWithSecret() error {
+	params := url.Values{}
+	params.Set("scope", "https://graph.microsoft.com/.default")
+	params.Set("client_id", graphClient.ClientId)
+	params.Set("client_secret", graphClient.auth.ClientSecret)
+	params.Set("grant_type", "client_credentials")
+
+	return graphClient.requestToken(params)
+}
+
+// loginWithCertificate authenticates using a signed JWT client assertion
+func (graphClient *GraphClient) loginWithCertificate() error {
+	assertion, err := graphClient.buildClientAssertion()
+
+	// Handle error
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Set("scope", "https://graph.microsoft.com/.default")
+	params.Set("client_id", graphClient.ClientId)
+	params.Set("client_assertion_type", clientAssertionType)
+	params.Set("client_assertion", assertion)
+	params.Set("grant_type", "client_credentials")
+
+	return graphClient.requestToken(params)
+}
+
+// buildClientAssertion signs an RS256 JWT client assertion from the configured
+// PEM private key and certificate thumbprint
+func (graphClient *GraphClient) buildClientAssertion() (string, error) {
+	keyBytes, err := ioutil.ReadFile(graphClient.auth.CertPath)
+
+	// Handle error
+	if err != nil {
+		return "", fmt.Errorf("unable to read certificate key: %v", err)
+	}
+
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return "", errors.New("unable to decode certificate key pem block")
+	}
+
+	key, err := parseRSAPrivateKey(block.Bytes)
+
+	// Handle error
+	if err != nil {
+		return "", fmt.Errorf("unable to parse certificate private key: %v", err)
+	}
+
+	thumbprintBytes, err := hex.DecodeString(graphClient.auth.CertThumbprint)
+
+	// Handle error
+	if err != nil {
+		return "", fmt.Errorf("unable to decode certificate thumbprint: %v", err)
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"aud": fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", graphClient.TenantId),
+		"iss": graphClient.ClientId,
+		"sub": graphClient.ClientId,
+		"jti": fmt.Sprintf("%d", now.UnixNano()),
+		"nbf": now.Unix(),
+		"exp": now.Add(certAssertionTTL).Unix(),
+	})
+	token.Header["x5t"] = base64.RawURLEncoding.EncodeToString(thumbprintBytes)
+
+	return token.SignedString(key)
+}
+
+// parseRSAPrivateKey accepts either PKCS1 or PKCS8 encoded RSA private keys
+func parseRSAPrivateKey(der []byte) (*rsa.PrivateKey, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	keyIface, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := keyIface.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("certificate private key is not RSA")
+	}
+
+	return key, nil
+}
+
+// loginWithManagedIdentity authenticates via the Azure Instance Metadata Service
+func (graphClient *GraphClient) loginWithManagedIdentity() error {
+	params := url.Values{}
+	params.Set("resource", graphResource)
+	params.Set("api-version", "2018-02-01")
+	if graphClient.auth.ManagedIdentityClientId != "" {
+		params.Set("client_id", graphClient.auth.ManagedIdentityClientId)
+	}
+
+	body, err := graphClient.conductRequestRaw("GET", imdsTokenUrl, params, "application/json", map[string]string{"Metadata": "true"})
+
+	// Handle error
+	if err != nil {
+		return errors.New(string(body))
+	}
+
+	return graphClient.applyTokenResponse(body)
+}
+
+// requestToken POSTs to the Azure AD token endpoint and applies the response
+func (graphClient *GraphClient) requestToken(params url.Values) error {
+	body, err := graphClient.conductRequestRaw("POST", fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", graphClient.TenantId), params, "application/x-www-form-urlencoded", nil)
+
+	// Handle error
+	if err != nil {
+		return errors.New(string(body))
+	}
+
+	return graphClient.applyTokenResponse(body)
+}
+
+// applyTokenResponse unmarshals an auth response and stores the token and its expiry
+func (graphClient *GraphClient) applyTokenResponse(body []byte) error {
+	var authResponse GraphAuthResponse
+	err := json.Unmarshal(body, &authResponse)
+
+	// Handle error
+	if err != nil {
+		return fmt.Errorf("error on unmarshal response body: %v", err)
+	}
+
+	expiresIn, err := authResponse.ExpiresIn.Int64()
+	if err != nil {
+		expiresIn = 0
+	}
+
+	graphClient.tokenMu.Lock()
+	graphClient.AccessToken = authResponse.AccessToken
+	graphClient.tokenExpiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	graphClient.tokenMu.Unlock()
+	graphClient.incAuthRefreshes()
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"fmt"
+	"time"
+)
+
+// Collector retrieves events for a single Microsoft Graph resource and sends
+// the results to the supplied channel. Each concrete collector owns its own
+// resource path and the field used to filter the poll window, so callers only
+// need to know the collector's name.
+type Collector interface {
+	// Name uniquely identifies the collector. It is used as the per-resource
+	// state key so each collector tracks its own last-poll timestamp.
+	Name() string
+	// Collect retrieves events created between since and until (RFC3339) and
+	// sends them to out, returning the number of events collected. filter, if
+	// non-empty, is ANDed onto the collector's own time-window $filter clause.
+	Collect(graphClient *GraphClient, since, until, filter string, out chan<- string) (int, error)
+	// SupportsDelta reports whether this collector can run in delta mode.
+	SupportsDelta() bool
+	// CollectDelta retrieves events using Graph delta queries, resuming from
+	// deltaLink (or bootstrapping if it's empty), and returns the new delta
+	// link to persist for the next run.
+	CollectDelta(graphClient *GraphClient, deltaLink string, out chan<- string) (int, string, error)
+	// SubscriptionResource reports the Graph resource path and changeType to
+	// subscribe to for change notifications, and the maximum lifetime Graph
+	// allows for that subscription. ok is false if the collector can't run in
+	// subscription mode and should always be polled instead.
+	SubscriptionResource() (resource string, changeType string, maxLifetime time.Duration, ok bool)
+}
+
+// timeWindowCollector collects events from a single Graph resource, either by
+// filtering on a timestamp field between two RFC3339 bounds, or - if
+// deltaResourcePath is set - via Graph delta queries.
+type timeWindowCollector struct {
+	name              string
+	resourcePath      string
+	filterField       string
+	deltaResourcePath string
+
+	// subscriptionResource and subscriptionChangeType are empty for
+	// collectors that don't support change-notification subscriptions.
+	subscriptionResource    string
+	subscriptionChangeType  string
+	subscriptionMaxLifetime time.Duration
+}
+
+// Name returns the collector's unique identifier.
+func (c *timeWindowCollector) Name() string {
+	return c.name
+}
+
+// Collect retrieves events for the collector's resource and filter field.
+func (c *timeWindowCollector) Collect(graphClient *GraphClient, since, until, filter string, out chan<- string) (int, error) {
+	return graphClient.collectByTimeWindow(c.resourcePath, c.filterField, since, until, filter, out)
+}
+
+// SupportsDelta reports whether this collector has a delta-capable resource.
+func (c *timeWindowCollector) SupportsDelta() bool {
+	return c.deltaResourcePath != ""
+}
+
+// CollectDelta retrieves events for the collector's delta resource.
+func (c *timeWindowCollector) CollectDelta(graphClient *GraphClient, deltaLink string, out chan<- string) (int, string, error) {
+	if !c.SupportsDelta() {
+		return -1, deltaLink, fmt.Errorf("collector %q does not support delta mode", c.name)
+	}
+
+	return graphClient.GetDelta(c.deltaResourcePath, deltaLink, out)
+}
+
+// SubscriptionResource reports this collector's change-notification resource,
+// if it has one.
+func (c *timeWindowCollector) SubscriptionResource() (string, string, time.Duration, bool) {
+	if c.subscriptionResource == "" {
+		return "", "", 0, false
+	}
+
+	return c.subscriptionResource, c.subscriptionChangeType, c.subscriptionMaxLifetime, true
+}
+
+// AlertsCollector collects Microsoft Graph security alerts.
+func AlertsCollector() Collector {
+	return &timeWindowCollector{
+		name:         "alerts",
+		resourcePath: "https://graph.microsoft.com/v1.0/security/alerts",
+		filterField:  "createdDateTime",
+	}
+}
+
+// DirectoryAuditsCollector collects Azure AD directory audit logs via delta query.
+func DirectoryAuditsCollector() Collector {
+	return &timeWindowCollector{
+		name:              "directoryAudits",
+		resourcePath:      "https://graph.microsoft.com/v1.0/auditLogs/directoryAudits",
+		filterField:       "activityDateTime",
+		deltaResourcePath: "https://graph.microsoft.com/v1.0/auditLogs/directoryAudits/delta",
+
+		// Directory resources get Graph's long-lived subscription window
+		subscriptionResource:    "auditLogs/directoryAudits",
+		subscriptionChangeType:  "updated",
+		subscriptionMaxLifetime: 71 * time.Hour,
+	}
+}
+
+// SignInsCollector collects Azure AD sign-in logs.
+func SignInsCollector() Collector {
+	return &timeWindowCollector{
+		name:         "signIns",
+		resourcePath: "https://graph.microsoft.com/v1.0/auditLogs/signIns",
+		filterField:  "createdDateTime",
+	}
+}
+
+// MessagesCollector collects Microsoft Teams messages across the tenant via delta query.
+func MessagesCollector() Collector {
+	return &timeWindowCollector{
+		name:              "messages",
+		resourcePath:      "https://graph.microsoft.com/beta/teams/getAllMessages",
+		filterField:       "createdDateTime",
+		deltaResourcePath: "https://graph.microsoft.com/beta/teams/getAllMessages/delta",
+
+		// Chat message resources cap subscriptions at 60 minutes
+		subscriptionResource:    "teams/getAllMessages",
+		subscriptionChangeType:  "created,updated",
+		subscriptionMaxLifetime: time.Hour,
+	}
+}
+
+// AvailableCollectors returns every collector supported by this client, keyed
+// by name.
+func AvailableCollectors() map[string]Collector {
+	collectors := []Collector{
+		AlertsCollector(),
+		DirectoryAuditsCollector(),
+		SignInsCollector(),
+		MessagesCollector(),
+	}
+
+	byName := make(map[string]Collector, len(collectors))
+	for _, c := range collectors {
+		byName[c.Name()] = c
+	}
+
+	return byName
+}
@@ -13,17 +13,10 @@ import (
 	"time"
 )
 
-const (
-	initialBackoffMS  = 1000
-	maxBackoffMS      = 32000
-	backoffFactor     = 2
-	rateLimitHttpCode = 429
-)
-
 // NewClient will initialize and return an authorized Graph Client
-func NewClient(tenantId, clientId, clientSecret string) (*GraphClient, error) {
+func NewClient(tenantId, clientId string, auth AuthConfig, retry RetryConfig, metrics Metrics) (*GraphClient, error) {
 	// Initialize client
-	graphClient := initClient(tenantId, clientId, clientSecret)
+	graphClient := initClient(tenantId, clientId, auth, retry, metrics)
 
 	// Login
 	err := graphClient.login()
@@ -37,49 +30,25 @@ func NewClient(tenantId, clientId, clientSecret string) (*GraphClient, error) {
 }
 
 // initClient will initialize and return a new Graph Client
-func initClient(tenantId, clientId, clientSecret string) *GraphClient {
+func initClient(tenantId, clientId string, auth AuthConfig, retry RetryConfig, metrics Metrics) *GraphClient {
 	return &GraphClient{
-		TenantId:     tenantId,
-		ClientId:     clientId,
-		ClientSecret: clientSecret,
-		AccessToken:  "",
+		TenantId:    tenantId,
+		ClientId:    clientId,
+		AccessToken: "",
+		auth:        auth,
+		retry:       retry,
+		metrics:     metrics,
 		httpClient: &http.Client{
 			Timeout: time.Second * 10,
 		},
 	}
 }
 
-// login will get a JWT with the correct grant type for collecting logs
-func (graphClient *GraphClient) login() error {
-	params := url.Values{}
-	params.Set("scope", "https://graph.microsoft.com/.default")
-	params.Set("client_id", graphClient.ClientId)
-	params.Set("client_secret", graphClient.ClientSecret)
-	params.Set("grant_type", "client_credentials")
-	body, err := graphClient.conductRequestRaw("POST", fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", graphClient.TenantId), params, "application/x-www-form-urlencoded")
-
-	// Handle errors
-	if err != nil {
-		return errors.New(string(body))
-	}
-
-	// Unmarshal response json
-	var authResponse GraphAuthResponse
-	err = json.Unmarshal(body, &authResponse)
-
-	// Handle error
-	if err != nil {
-		return errors.New(fmt.Sprintf("error on unmarshal response body: %v", err))
-	}
-
-	// Set access token
-	graphClient.AccessToken = authResponse.AccessToken
-
-	return nil
-}
-
-// GetAlerts will retrieve the events between the two supplied timestamps and send the results to the channel
-func (graphClient *GraphClient) GetAlerts(lastPollTimestamp, currentTimestamp string, resultsChannel chan<- string) (int, error) {
+// collectByTimeWindow will retrieve events for a resource between the two supplied
+// timestamps, filtering on filterField, and send the results to the channel. extraFilter,
+// if non-empty, is ANDed onto the time-window clause verbatim (e.g. a per-resource
+// config override). This is the shared paging helper every Collector is built on top of.
+func (graphClient *GraphClient) collectByTimeWindow(resourcePath, filterField, lastPollTimestamp, currentTimestamp, extraFilter string, resultsChannel chan<- string) (int, error) {
 	// Setup variable
 	count := 0
 
@@ -106,19 +75,24 @@ func (graphClient *GraphClient) GetAlerts(lastPollTimestamp, currentTimestamp st
 	leTime := currentPollTime.UTC().Format("2006-01-02T15:04:05Z")
 
 	// Set up parameters
+	filterClause := fmt.Sprintf("%s gt %s and %s le %s", filterField, gtTime, filterField, leTime)
+	if extraFilter != "" {
+		filterClause = fmt.Sprintf("%s and (%s)", filterClause, extraFilter)
+	}
+
 	params := url.Values{}
-	params.Set("$filter", "createdDateTime gt "+gtTime+" and createdDateTime le "+leTime)
+	params.Set("$filter", filterClause)
 
 	// Conduct request
-	body, err := graphClient.conductRequest("GET", "https://graph.microsoft.com/v1.0/security/alerts", params)
+	body, err := graphClient.conductRequest("GET", resourcePath, params)
 
 	// Handle error
 	if err != nil {
 		return -1, err
 	}
 
-	// Parse Graph Security Alerts
-	var response GraphSecurityAlertsResponse
+	// Parse the paged Graph response
+	var response GraphListResponse
 	err = json.Unmarshal(body, &response)
 
 	// Handle error
@@ -168,7 +142,7 @@ func (graphClient *GraphClient) GetAlerts(lastPollTimestamp, currentTimestamp st
 		params.Set("$skiptoken", skipToken)
 
 		// Do request
-		body, err = graphClient.conductRequest("GET", "https://graph.microsoft.com/v1.0/security/alerts", params)
+		body, err = graphClient.conductRequest("GET", resourcePath, params)
 
 		// Handle error
 		if err != nil {
@@ -211,13 +185,112 @@ func (graphClient *GraphClient) GetAlerts(lastPollTimestamp, currentTimestamp st
 	return count, nil
 }
 
-// conductRequest conducts a json request
+// GetDelta will retrieve events for a resource using Microsoft Graph delta queries.
+// If deltaLink is empty this performs the initial /delta call against resourcePath;
+// otherwise it resumes from the saved deltaLink. It loops @odata.nextLink pages and
+// returns the @odata.deltaLink to persist for the next run.
+func (graphClient *GraphClient) GetDelta(resourcePath, deltaLink string, resultsChannel chan<- string) (int, string, error) {
+	// Setup variable
+	count := 0
+
+	// Resume from the saved delta link if we have one, otherwise bootstrap
+	requestUrl := resourcePath
+	if deltaLink != "" {
+		requestUrl = deltaLink
+	}
+	newDeltaLink := deltaLink
+
+	for {
+		// Conduct request
+		body, err := graphClient.conductRequest("GET", requestUrl, url.Values{})
+
+		// Handle error
+		if err != nil {
+			return -1, newDeltaLink, err
+		}
+
+		// Parse the delta response
+		var response GraphListResponse
+		err = json.Unmarshal(body, &response)
+
+		// Handle error
+		if err != nil {
+			return -1, newDeltaLink, err
+		}
+
+		// Send events to results channel
+		if len(response.Value) > 0 {
+			// Convert results to array of strings
+			data := convertInterfaceToString(response.Value)
+
+			// Add current data count
+			count += len(data)
+
+			// Send events to results channel
+			for _, event := range data {
+				resultsChannel <- string(pretty.Ugly([]byte(event)))
+			}
+		}
+
+		// Print number of results
+		log.Debugf("delta response had %v values", len(response.Value))
+
+		// A deltaLink means we've caught up to the end of this round of changes
+		if response.DeltaLink != "" {
+			newDeltaLink = response.DeltaLink
+			break
+		}
+
+		// No delta link and no more pages means there's nothing further to do
+		if response.NextLink == "" {
+			break
+		}
+
+		requestUrl = response.NextLink
+	}
+
+	return count, newDeltaLink, nil
+}
+
+// FlattenResource normalizes a single Graph GET response (e.g. a change
+// notification's resource fetch) into the same shape every other producer
+// writes to the results channel: one compact (pretty.Ugly'd) JSON object per
+// event. A response shaped like a paged collection (an "@odata.context"/
+// "value" envelope) is unwrapped into one event per entry; anything else is
+// treated as a single event.
+func FlattenResource(body []byte) []string {
+	var response GraphListResponse
+	if err := json.Unmarshal(body, &response); err == nil && response.Value != nil {
+		data := convertInterfaceToString(response.Value)
+		events := make([]string, len(data))
+		for i, event := range data {
+			events[i] = string(pretty.Ugly([]byte(event)))
+		}
+		return events
+	}
+
+	return []string{string(pretty.Ugly(body))}
+}
+
+// IsDeltaExpired reports whether err came back from a delta query whose
+// deltaLink is no longer valid (Graph returns HTTP 410 Gone), meaning the
+// caller needs to re-initialize delta from scratch.
+func IsDeltaExpired(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "410")
+}
+
+// conductRequest conducts an authorized json request against the Graph API,
+// proactively refreshing the access token first if it's missing or expiring
 func (graphClient *GraphClient) conductRequest(method string, uri string, params url.Values) ([]byte, error) {
-	return graphClient.conductRequestRaw(method, uri, params, "application/json")
+	if err := graphClient.ensureToken(); err != nil {
+		return nil, err
+	}
+
+	return graphClient.conductRequestRaw(method, uri, params, "application/json", nil)
 }
 
 // conductRequestRaw will build the correct request and handle any errors
-func (graphClient *GraphClient) conductRequestRaw(method string, uri string, params url.Values, contentType string) ([]byte, error) {
+func (graphClient *GraphClient) conductRequestRaw(method string, uri string, params url.Values, contentType string, extraHeaders map[string]string) ([]byte, error) {
 	// Build the URL
 	aptUrl, err := url.Parse(uri)
 
@@ -230,6 +303,9 @@ func (graphClient *GraphClient) conductRequestRaw(method string, uri string, par
 	headers := make(map[string]string)
 	headers["Accept"] = "*/*"
 	headers["Content-Type"] = contentType
+	for k, v := range extraHeaders {
+		headers[k] = v
+	}
 
 	// Convert method to uppercase
 	method = strings.ToUpper(method)
@@ -237,9 +313,16 @@ func (graphClient *GraphClient) conductRequestRaw(method string, uri string, par
 	// JSON marshal body
 	var requestBody string = ""
 
-	// Encode params if GET request
+	// Encode params if GET request, merging into any query string the uri already
+	// carries (e.g. a Graph nextLink/deltaLink that already has its own params)
 	if method == "GET" {
-		aptUrl.RawQuery = params.Encode()
+		query := aptUrl.Query()
+		for key, values := range params {
+			for _, value := range values {
+				query.Add(key, value)
+			}
+		}
+		aptUrl.RawQuery = query.Encode()
 	} else if method == "POST" || method == "PUT" {
 		if contentType == "application/x-www-form-urlencoded" {
 			requestBody = params.Encode()
@@ -263,15 +346,18 @@ func (graphClient *GraphClient) conductRequestRaw(method string, uri string, par
 	return body, nil
 }
 
-// makeRetryableHttpCall will conduct an HTTP request and handle retries with backoff for rate limit responses
+// makeRetryableHttpCall will conduct an HTTP request and retry transient
+// failures (429/5xx responses and network errors) with backoff, honoring
+// Retry-After when the server supplies one
 func (graphClient *GraphClient) makeRetryableHttpCall(
 	method string,
 	urlObj url.URL,
 	headers map[string]string,
 	body string,
 ) (*http.Response, []byte, error) {
-	backoffMs := initialBackoffMS
-	for {
+	retry := graphClient.retry
+
+	for attempt := 0; ; attempt++ {
 		var request *http.Request
 		var err error
 
@@ -294,43 +380,62 @@ func (graphClient *GraphClient) makeRetryableHttpCall(
 			}
 		}
 
-		// Set access token if exists
-		if graphClient.AccessToken != "" {
-			request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", graphClient.AccessToken))
+		// Set access token if exists. Read through the locked accessor, not the
+		// struct field directly - every collector shares this GraphClient, and
+		// a concurrent ensureToken() can be rewriting AccessToken right now.
+		if token := graphClient.accessToken(); token != "" {
+			request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 		}
 
 		// Conduct request
+		requestStart := time.Now()
 		resp, err := graphClient.httpClient.Do(request)
-		var body []byte
-
-		// Return non 200 and non rate limit responses
-		if err != nil || (resp.StatusCode != 200 && resp.StatusCode != rateLimitHttpCode) {
-			// Warn on 206 Partial Content
-			if resp.StatusCode == 206 {
-				log.Warnf("header present - `Warning: %v`", resp.Header.Get("Warning"))
-				log.Warnf("this means that a MS provider returned an error code")
-				log.Warnf("see: https://docs.microsoft.com/en-us/graph/api/resources/security-error-codes?view=graph-rest-1.0")
+		graphClient.observeRequestLatency(time.Since(requestStart).Seconds())
+
+		// Network errors never reach a response, so retry them on their own path
+		// rather than dereferencing a nil resp below
+		if err != nil {
+			if !retry.shouldRetry(attempt) {
+				return nil, nil, err
 			}
 
-			body, err = ioutil.ReadAll(resp.Body)
-			resp.Body.Close()
+			graphClient.incHttpRetries()
+			sleepMs := retry.sleepDuration(attempt, 0)
+			log.Warnf("retrying after transport error (attempt %d/%d, sleeping %dms): %v", attempt+1, retry.MaxRetries, sleepMs, err)
+			time.Sleep(time.Millisecond * time.Duration(sleepMs))
+			continue
+		}
 
-			if err == nil {
-				return resp, body, errors.New(resp.Status)
-			}
-			return resp, body, err
+		// Warn on 206 Partial Content
+		if resp.StatusCode == 206 {
+			log.Warnf("header present - `Warning: %v`", resp.Header.Get("Warning"))
+			log.Warnf("this means that a MS provider returned an error code")
+			log.Warnf("see: https://docs.microsoft.com/en-us/graph/api/resources/security-error-codes?view=graph-rest-1.0")
+		}
+
+		respBody, readErr := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode == 200 {
+			return resp, respBody, readErr
 		}
 
-		// Handle backup or non rate limit
-		if backoffMs > maxBackoffMS || resp.StatusCode != rateLimitHttpCode {
-			body, err = ioutil.ReadAll(resp.Body)
-			resp.Body.Close()
-			return resp, body, err
+		if resp.StatusCode == http.StatusTooManyRequests {
+			graphClient.incRateLimitHits()
+		}
+
+		if !retry.isRetryableStatus(resp.StatusCode) || !retry.shouldRetry(attempt) {
+			if readErr == nil {
+				return resp, respBody, errors.New(resp.Status)
+			}
+			return resp, respBody, readErr
 		}
 
-		// Sleep to retry due to rate limit response
-		time.Sleep(time.Millisecond * time.Duration(backoffMs))
-		backoffMs *= backoffFactor
+		// Sleep to retry due to a transient response
+		graphClient.incHttpRetries()
+		sleepMs := retry.sleepDuration(attempt, retryAfterMs(resp))
+		log.Warnf("retrying %v (attempt %d/%d, status %v, sleeping %dms)", urlObj.String(), attempt+1, retry.MaxRetries, resp.Status, sleepMs)
+		time.Sleep(time.Millisecond * time.Duration(sleepMs))
 	}
 }
 
@@ -0,0 +1,35 @@
+package client
+
+// Metrics receives observability counters from a GraphClient. A nil Metrics
+// is a valid no-op, so callers that don't care about metrics (e.g. tests) can
+// simply leave it unset.
+type Metrics interface {
+	IncHttpRetries()
+	IncRateLimitHits()
+	IncAuthRefreshes()
+	ObserveRequestLatency(seconds float64)
+}
+
+func (graphClient *GraphClient) incHttpRetries() {
+	if graphClient.metrics != nil {
+		graphClient.metrics.IncHttpRetries()
+	}
+}
+
+func (graphClient *GraphClient) incRateLimitHits() {
+	if graphClient.metrics != nil {
+		graphClient.metrics.IncRateLimitHits()
+	}
+}
+
+func (graphClient *GraphClient) incAuthRefreshes() {
+	if graphClient.metrics != nil {
+		graphClient.metrics.IncAuthRefreshes()
+	}
+}
+
+func (graphClient *GraphClient) observeRequestLatency(seconds float64) {
+	if graphClient.metrics != nil {
+		graphClient.metrics.ObserveRequestLatency(seconds)
+	}
+}
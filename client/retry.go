@@ -0,0 +1,120 @@
+package client
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	initialBackoffMS = 1000
+	maxBackoffMS     = 32000
+	backoffFactor    = 2
+	maxRetries       = 6
+)
+
+// RetryConfig controls how a GraphClient retries a failed HTTP call.
+type RetryConfig struct {
+	// MaxRetries is how many times a failed request is retried after the
+	// initial attempt; 0 disables retries entirely.
+	MaxRetries int
+
+	// InitialBackoffMs and MaxBackoffMs bound the exponential backoff delay.
+	InitialBackoffMs int
+	MaxBackoffMs     int
+
+	// Multiplier is applied to the backoff after every retry.
+	Multiplier float64
+
+	// JitterFraction randomizes the sleep by up to this fraction (0-1) of the
+	// computed backoff; 1 means full jitter (a random delay between 0 and the
+	// computed backoff), 0 disables jitter.
+	JitterFraction float64
+
+	// RetryableStatus is the set of HTTP status codes that are retried.
+	RetryableStatus map[int]bool
+}
+
+// DefaultRetryConfig mirrors the client's original hardcoded retry behavior,
+// plus 5xx coverage and full jitter.
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxRetries:       maxRetries,
+		InitialBackoffMs: initialBackoffMS,
+		MaxBackoffMs:     maxBackoffMS,
+		Multiplier:       backoffFactor,
+		JitterFraction:   1,
+		RetryableStatus: map[int]bool{
+			http.StatusTooManyRequests:     true,
+			http.StatusInternalServerError: true,
+			http.StatusBadGateway:          true,
+			http.StatusServiceUnavailable:  true,
+			http.StatusGatewayTimeout:      true,
+		},
+	}
+}
+
+// shouldRetry reports whether attempt (0-indexed) is still within budget
+func (r RetryConfig) shouldRetry(attempt int) bool {
+	return attempt < r.MaxRetries
+}
+
+// isRetryableStatus reports whether status is in the configured retryable set
+func (r RetryConfig) isRetryableStatus(status int) bool {
+	return r.RetryableStatus[status]
+}
+
+// backoffForAttempt returns the exponential backoff, before jitter and before
+// any Retry-After override, for the given retry attempt (0-indexed)
+func (r RetryConfig) backoffForAttempt(attempt int) int {
+	backoff := float64(r.InitialBackoffMs) * math.Pow(r.Multiplier, float64(attempt))
+	if backoff > float64(r.MaxBackoffMs) {
+		backoff = float64(r.MaxBackoffMs)
+	}
+
+	return int(backoff)
+}
+
+// sleepDuration picks the delay in milliseconds before the next retry,
+// honoring a server-supplied Retry-After (clamped to MaxBackoffMs) over the
+// computed exponential backoff, and applying jitter when there is no override.
+func (r RetryConfig) sleepDuration(attempt int, retryAfterMs int) int {
+	if retryAfterMs > 0 {
+		if retryAfterMs > r.MaxBackoffMs {
+			return r.MaxBackoffMs
+		}
+		return retryAfterMs
+	}
+
+	backoff := r.backoffForAttempt(attempt)
+	if r.JitterFraction <= 0 {
+		return backoff
+	}
+
+	jitterRange := int(float64(backoff) * r.JitterFraction)
+	return backoff - jitterRange + rand.Intn(jitterRange+1)
+}
+
+// retryAfterMs parses a response's Retry-After header (seconds or HTTP-date)
+// into milliseconds, returning 0 if the header is absent, unparseable, or in
+// the past.
+func retryAfterMs(resp *http.Response) int {
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return seconds * 1000
+	}
+
+	if when, err := http.ParseTime(header); err == nil {
+		if wait := time.Until(when); wait > 0 {
+			return int(wait.Milliseconds())
+		}
+	}
+
+	return 0
+}
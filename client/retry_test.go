@@ -0,0 +1,145 @@
+package client
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestShouldRetry(t *testing.T) {
+	cases := []struct {
+		name       string
+		maxRetries int
+		attempt    int
+		want       bool
+	}{
+		{"first attempt within budget", 3, 0, true},
+		{"last attempt within budget", 3, 2, true},
+		{"attempt exhausts budget", 3, 3, false},
+		{"attempt past budget", 3, 4, false},
+		{"retries disabled", 0, 0, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := RetryConfig{MaxRetries: tc.maxRetries}
+			if got := r.shouldRetry(tc.attempt); got != tc.want {
+				t.Errorf("shouldRetry(%d) with MaxRetries=%d = %v, want %v", tc.attempt, tc.maxRetries, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBackoffForAttempt(t *testing.T) {
+	r := RetryConfig{InitialBackoffMs: 1000, MaxBackoffMs: 32000, Multiplier: 2}
+
+	cases := []struct {
+		attempt int
+		want    int
+	}{
+		{0, 1000},
+		{1, 2000},
+		{2, 4000},
+		{3, 8000},
+		{4, 16000},
+		{5, 32000},
+		// Clamped: 1000*2^6 = 64000, which exceeds MaxBackoffMs
+		{6, 32000},
+	}
+
+	for _, tc := range cases {
+		if got := r.backoffForAttempt(tc.attempt); got != tc.want {
+			t.Errorf("backoffForAttempt(%d) = %d, want %d", tc.attempt, got, tc.want)
+		}
+	}
+}
+
+func TestSleepDurationHonorsRetryAfter(t *testing.T) {
+	r := RetryConfig{InitialBackoffMs: 1000, MaxBackoffMs: 32000, Multiplier: 2, JitterFraction: 1}
+
+	cases := []struct {
+		name         string
+		retryAfterMs int
+		want         int
+	}{
+		{"within max", 5000, 5000},
+		{"clamped to max", 60000, 32000},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := r.sleepDuration(0, tc.retryAfterMs); got != tc.want {
+				t.Errorf("sleepDuration(0, %d) = %d, want %d", tc.retryAfterMs, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSleepDurationWithoutJitterMatchesBackoff(t *testing.T) {
+	r := RetryConfig{InitialBackoffMs: 1000, MaxBackoffMs: 32000, Multiplier: 2, JitterFraction: 0}
+
+	if got, want := r.sleepDuration(2, 0), r.backoffForAttempt(2); got != want {
+		t.Errorf("sleepDuration(2, 0) = %d, want %d (no jitter)", got, want)
+	}
+}
+
+func TestSleepDurationJitterStaysInRange(t *testing.T) {
+	r := RetryConfig{InitialBackoffMs: 1000, MaxBackoffMs: 32000, Multiplier: 2, JitterFraction: 0.5}
+	backoff := r.backoffForAttempt(3)
+	lower := backoff - int(float64(backoff)*0.5)
+
+	for i := 0; i < 50; i++ {
+		got := r.sleepDuration(3, 0)
+		if got < lower || got > backoff {
+			t.Fatalf("sleepDuration(3, 0) = %d, want in [%d, %d]", got, lower, backoff)
+		}
+	}
+}
+
+func TestRetryAfterMsSeconds(t *testing.T) {
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"5"}}}
+	if got, want := retryAfterMs(resp), 5000; got != want {
+		t.Errorf("retryAfterMs(seconds) = %d, want %d", got, want)
+	}
+}
+
+func TestRetryAfterMsHttpDate(t *testing.T) {
+	when := time.Now().Add(10 * time.Second)
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{when.UTC().Format(http.TimeFormat)}}}
+
+	got := retryAfterMs(resp)
+	if got <= 0 || got > 10000 {
+		t.Errorf("retryAfterMs(HTTP-date 10s out) = %d, want in (0, 10000]", got)
+	}
+}
+
+func TestRetryAfterMsPastDate(t *testing.T) {
+	when := time.Now().Add(-10 * time.Second)
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{when.UTC().Format(http.TimeFormat)}}}
+
+	if got := retryAfterMs(resp); got != 0 {
+		t.Errorf("retryAfterMs(past HTTP-date) = %d, want 0", got)
+	}
+}
+
+func TestRetryAfterMsMissingOrUnparseable(t *testing.T) {
+	cases := []struct {
+		name   string
+		header string
+	}{
+		{"missing", ""},
+		{"garbage", "not-a-number-or-date"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tc.header != "" {
+				resp.Header.Set("Retry-After", tc.header)
+			}
+			if got := retryAfterMs(resp); got != 0 {
+				t.Errorf("retryAfterMs(%q) = %d, want 0", tc.header, got)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package client
 import (
 	"encoding/json"
 	"net/http"
+	"sync"
+	"time"
 )
 
 type GraphAuthResponse struct {
@@ -13,15 +15,47 @@ type GraphAuthResponse struct {
 }
 
 type GraphClient struct {
-	TenantId     string `json:"tenant_id"`
-	ClientId     string `json:"client_id"`
-	ClientSecret string `json:"client_secret"`
-	AccessToken  string `json:"access_token"`
-	httpClient   *http.Client
+	TenantId    string `json:"tenant_id"`
+	ClientId    string `json:"client_id"`
+	AccessToken string `json:"access_token"`
+
+	// auth holds whichever credentials were configured for this client, and
+	// tokenExpiry/tokenMu back the proactive refresh in ensureToken.
+	auth        AuthConfig
+	tokenExpiry time.Time
+	tokenMu     sync.Mutex
+
+	// retry controls how makeRetryableHttpCall backs off on transient failures
+	retry RetryConfig
+
+	// metrics receives observability counters; nil if none were configured
+	metrics Metrics
+
+	httpClient *http.Client
+}
+
+// TokenExpiry returns the current access token's expiry, for status reporting
+func (graphClient *GraphClient) TokenExpiry() time.Time {
+	graphClient.tokenMu.Lock()
+	defer graphClient.tokenMu.Unlock()
+	return graphClient.tokenExpiry
+}
+
+// accessToken returns the current access token under tokenMu, since every
+// collector shares this GraphClient and a concurrent ensureToken can be
+// rewriting AccessToken while another collector's request reads it.
+func (graphClient *GraphClient) accessToken() string {
+	graphClient.tokenMu.Lock()
+	defer graphClient.tokenMu.Unlock()
+	return graphClient.AccessToken
 }
 
-type GraphSecurityAlertsResponse struct {
-	Context  string        `json:"@odata.context"`
-	NextLink string        `json:"@odata.nextLink"`
-	Value    []interface{} `json:"value"`
+// GraphListResponse models the shared shape of a paged Graph collection
+// response, regardless of which resource it came from. DeltaLink is only
+// populated by delta queries, once a page reaches the end of the change set.
+type GraphListResponse struct {
+	Context   string        `json:"@odata.context"`
+	NextLink  string        `json:"@odata.nextLink"`
+	DeltaLink string        `json:"@odata.deltaLink"`
+	Value     []interface{} `json:"value"`
 }
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const subscriptionsResourcePath = "https://graph.microsoft.com/v1.0/subscriptions"
+
+// Subscription mirrors a Microsoft Graph change notification subscription.
+type Subscription struct {
+	ID                 string `json:"id,omitempty"`
+	Resource           string `json:"resource"`
+	ChangeType         string `json:"changeType"`
+	NotificationUrl    string `json:"notificationUrl"`
+	ClientState        string `json:"clientState,omitempty"`
+	ExpirationDateTime string `json:"expirationDateTime"`
+}
+
+// CreateSubscription registers a Graph change-notification subscription for
+// resource. Graph synchronously calls notificationUrl to validate it before
+// this returns, so the caller's webhook receiver must already be listening.
+func (graphClient *GraphClient) CreateSubscription(resource, changeType, notificationUrl, clientState string, expiration time.Time) (*Subscription, error) {
+	payload := Subscription{
+		Resource:           resource,
+		ChangeType:         changeType,
+		NotificationUrl:    notificationUrl,
+		ClientState:        clientState,
+		ExpirationDateTime: expiration.UTC().Format(time.RFC3339),
+	}
+
+	body, err := graphClient.conductJSONRequest("POST", subscriptionsResourcePath, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var created Subscription
+	if err := json.Unmarshal(body, &created); err != nil {
+		return nil, fmt.Errorf("error on unmarshal subscription response: %v", err)
+	}
+
+	return &created, nil
+}
+
+// RenewSubscription extends an existing subscription's expiration so it
+// doesn't lapse at its per-resource maximum lifetime.
+func (graphClient *GraphClient) RenewSubscription(id string, expiration time.Time) error {
+	payload := struct {
+		ExpirationDateTime string `json:"expirationDateTime"`
+	}{ExpirationDateTime: expiration.UTC().Format(time.RFC3339)}
+
+	_, err := graphClient.conductJSONRequest("PATCH", fmt.Sprintf("%s/%s", subscriptionsResourcePath, id), payload)
+	return err
+}
+
+// GetSubscription fetches a subscription by ID, used on startup to check
+// whether a previously created subscription is still registered with Graph.
+func (graphClient *GraphClient) GetSubscription(id string) (*Subscription, error) {
+	body, err := graphClient.conductRequest("GET", fmt.Sprintf("%s/%s", subscriptionsResourcePath, id), url.Values{})
+	if err != nil {
+		return nil, err
+	}
+
+	var sub Subscription
+	if err := json.Unmarshal(body, &sub); err != nil {
+		return nil, fmt.Errorf("error on unmarshal subscription response: %v", err)
+	}
+
+	return &sub, nil
+}
+
+// DeleteSubscription removes a subscription by ID.
+func (graphClient *GraphClient) DeleteSubscription(id string) error {
+	_, err := graphClient.conductRequestRaw("DELETE", fmt.Sprintf("%s/%s", subscriptionsResourcePath, id), url.Values{}, "application/json", nil)
+	return err
+}
+
+// GetResource issues a targeted GET for a single Graph resource (e.g. the
+// resource named by a change notification) and returns the raw JSON body.
+func (graphClient *GraphClient) GetResource(resourcePath string) ([]byte, error) {
+	return graphClient.conductRequest("GET", resourcePath, url.Values{})
+}
+
+// conductJSONRequest issues an authorized request with payload marshaled as
+// the request body. conductRequest only models flat form/query params
+// (url.Values), which can't express the nested subscription bodies Graph
+// expects here.
+func (graphClient *GraphClient) conductJSONRequest(method, uri string, payload interface{}) ([]byte, error) {
+	if err := graphClient.ensureToken(); err != nil {
+		return nil, err
+	}
+
+	bodyBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	aptUrl, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{"Accept": "*/*", "Content-Type": "application/json"}
+	_, body, err := graphClient.makeRetryableHttpCall(strings.ToUpper(method), *aptUrl, headers, string(bodyBytes))
+	return body, err
+}
@@ -0,0 +1,140 @@
+package configfile
+
+// AuthSection mirrors the --auth-mode family of flags.
+type AuthSection struct {
+	Mode                    string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	ClientSecret            string `json:"client_secret,omitempty" yaml:"client_secret,omitempty"`
+	CertPath                string `json:"cert_path,omitempty" yaml:"cert_path,omitempty"`
+	CertThumbprint          string `json:"cert_thumbprint,omitempty" yaml:"cert_thumbprint,omitempty"`
+	ManagedIdentityClientId string `json:"managed_identity_client_id,omitempty" yaml:"managed_identity_client_id,omitempty"`
+}
+
+// RetrySection mirrors the --max-retries/--initial-backoff-ms/--max-backoff-ms flags.
+type RetrySection struct {
+	MaxRetries       int `json:"max_retries,omitempty" yaml:"max_retries,omitempty"`
+	InitialBackoffMs int `json:"initial_backoff_ms,omitempty" yaml:"initial_backoff_ms,omitempty"`
+	MaxBackoffMs     int `json:"max_backoff_ms,omitempty" yaml:"max_backoff_ms,omitempty"`
+}
+
+// ResourceSection configures a single collector. Schedule and Mode fall back
+// to the process-wide --schedule/--mode flags when zero/empty: Mode may only
+// narrow --mode=subscription down to "poll" for this one resource, since
+// subscribing at all still requires --mode=subscription (and its
+// --notification-url/--webhook-listen) to be set globally. Filter is ANDed
+// onto the collector's own $filter clause (poll mode only, ignored by
+// delta-capable collectors, which don't use one).
+type ResourceSection struct {
+	Name     string `json:"name" yaml:"name"`
+	Schedule int    `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	Mode     string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Filter   string `json:"filter,omitempty" yaml:"filter,omitempty"`
+}
+
+// StateSection mirrors the --state-path flag.
+type StateSection struct {
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+// FileOutputSection mirrors the --file/--file-path/--file-rotate flags.
+type FileOutputSection struct {
+	Enabled bool   `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	Path    string `json:"path,omitempty" yaml:"path,omitempty"`
+	Rotate  bool   `json:"rotate,omitempty" yaml:"rotate,omitempty"`
+}
+
+// PubSubOutputSection mirrors the --pubsub family of flags.
+type PubSubOutputSection struct {
+	Enabled     bool   `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	Project     string `json:"project,omitempty" yaml:"project,omitempty"`
+	Topic       string `json:"topic,omitempty" yaml:"topic,omitempty"`
+	Credentials string `json:"credentials,omitempty" yaml:"credentials,omitempty"`
+}
+
+// GCSOutputSection mirrors the --gcs family of flags.
+type GCSOutputSection struct {
+	Enabled     bool   `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	Bucket      string `json:"bucket,omitempty" yaml:"bucket,omitempty"`
+	Path        string `json:"path,omitempty" yaml:"path,omitempty"`
+	Composite   bool   `json:"composite,omitempty" yaml:"composite,omitempty"`
+	Credentials string `json:"credentials,omitempty" yaml:"credentials,omitempty"`
+}
+
+// S3OutputSection mirrors the --s3 family of flags.
+type S3OutputSection struct {
+	Enabled      bool   `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	Region       string `json:"region,omitempty" yaml:"region,omitempty"`
+	Bucket       string `json:"bucket,omitempty" yaml:"bucket,omitempty"`
+	Path         string `json:"path,omitempty" yaml:"path,omitempty"`
+	AccessKeyId  string `json:"access_key_id,omitempty" yaml:"access_key_id,omitempty"`
+	SecretKey    string `json:"secret_key,omitempty" yaml:"secret_key,omitempty"`
+	StorageClass string `json:"storage_class,omitempty" yaml:"storage_class,omitempty"`
+}
+
+// StackdriverOutputSection mirrors the --stackdriver family of flags.
+type StackdriverOutputSection struct {
+	Enabled     bool   `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	Project     string `json:"project,omitempty" yaml:"project,omitempty"`
+	LogName     string `json:"log_name,omitempty" yaml:"log_name,omitempty"`
+	Credentials string `json:"credentials,omitempty" yaml:"credentials,omitempty"`
+}
+
+// HTTPOutputSection mirrors the --http family of flags.
+type HTTPOutputSection struct {
+	Enabled  bool   `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	Url      string `json:"url,omitempty" yaml:"url,omitempty"`
+	Auth     string `json:"auth,omitempty" yaml:"auth,omitempty"`
+	MaxItems int    `json:"max_items,omitempty" yaml:"max_items,omitempty"`
+}
+
+// ElasticOutputSection mirrors the --elastic/--elasticsearch family of flags.
+type ElasticOutputSection struct {
+	Enabled  bool     `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	Cloud    bool     `json:"cloud,omitempty" yaml:"cloud,omitempty"`
+	CloudId  string   `json:"cloud_id,omitempty" yaml:"cloud_id,omitempty"`
+	Urls     []string `json:"urls,omitempty" yaml:"urls,omitempty"`
+	Index    string   `json:"index,omitempty" yaml:"index,omitempty"`
+	ApiKey   string   `json:"api_key,omitempty" yaml:"api_key,omitempty"`
+	Username string   `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string   `json:"password,omitempty" yaml:"password,omitempty"`
+	CaCert   string   `json:"ca_cert,omitempty" yaml:"ca_cert,omitempty"`
+}
+
+// OutputsSection mirrors the collector-helpers outputs package's per-backend
+// flags - one sub-section per output, all disabled/empty unless configured.
+type OutputsSection struct {
+	File        FileOutputSection        `json:"file,omitempty" yaml:"file,omitempty"`
+	PubSub      PubSubOutputSection      `json:"pubsub,omitempty" yaml:"pubsub,omitempty"`
+	GCS         GCSOutputSection         `json:"gcs,omitempty" yaml:"gcs,omitempty"`
+	S3          S3OutputSection          `json:"s3,omitempty" yaml:"s3,omitempty"`
+	Stackdriver StackdriverOutputSection `json:"stackdriver,omitempty" yaml:"stackdriver,omitempty"`
+	HTTP        HTTPOutputSection        `json:"http,omitempty" yaml:"http,omitempty"`
+	Elastic     ElasticOutputSection     `json:"elasticsearch,omitempty" yaml:"elasticsearch,omitempty"`
+}
+
+// Config models the full collector configuration that used to be expressible
+// only as flags/env vars. Every field is optional - values present here
+// become defaults that an explicitly set flag or env var still overrides.
+type Config struct {
+	TenantId        string            `json:"tenant_id,omitempty" yaml:"tenant_id,omitempty"`
+	ClientId        string            `json:"client_id,omitempty" yaml:"client_id,omitempty"`
+	Auth            AuthSection       `json:"auth,omitempty" yaml:"auth,omitempty"`
+	Retry           RetrySection      `json:"retry,omitempty" yaml:"retry,omitempty"`
+	Resources       []ResourceSection `json:"resources,omitempty" yaml:"resources,omitempty"`
+	NotificationUrl string            `json:"notification_url,omitempty" yaml:"notification_url,omitempty"`
+	AdminListen     string            `json:"admin_listen,omitempty" yaml:"admin_listen,omitempty"`
+	WebhookListen   string            `json:"webhook_listen,omitempty" yaml:"webhook_listen,omitempty"`
+	State           StateSection      `json:"state,omitempty" yaml:"state,omitempty"`
+	Outputs         OutputsSection    `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+}
+
+// ResourceByName returns the resource section named name, if the config
+// declares one.
+func (c Config) ResourceByName(name string) (ResourceSection, bool) {
+	for _, r := range c.Resources {
+		if r.Name == name {
+			return r, true
+		}
+	}
+
+	return ResourceSection{}, false
+}
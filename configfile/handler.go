@@ -0,0 +1,147 @@
+package configfile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ErrFingerprintMismatch is returned by DoLockedAction when the caller's
+// fingerprint no longer matches the config in memory, meaning it read a
+// stale copy and must re-fetch before retrying.
+var ErrFingerprintMismatch = errors.New("config fingerprint mismatch")
+
+// ConfigHandler owns the structured config file on disk: the in-memory copy,
+// its content fingerprint, and (de)serialization to either YAML or JSON
+// depending on the file's extension.
+type ConfigHandler struct {
+	path string
+
+	mu  sync.RWMutex
+	cfg Config
+}
+
+// Load reads and parses path, choosing YAML or JSON based on its extension
+// (.yaml/.yml vs .json).
+func Load(path string) (*ConfigHandler, error) {
+	h := &ConfigHandler{path: path}
+	if err := h.reload(); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// Config returns a copy of the currently loaded configuration.
+func (h *ConfigHandler) Config() Config {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.cfg
+}
+
+// Fingerprint returns a content hash of the currently loaded configuration,
+// for optimistic-concurrency checks against concurrent edits.
+func (h *ConfigHandler) Fingerprint() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return fingerprintOf(h.cfg)
+}
+
+// MarshalJSON serializes the currently loaded configuration.
+func (h *ConfigHandler) MarshalJSON() ([]byte, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return json.Marshal(h.cfg)
+}
+
+// UnmarshalJSON replaces the in-memory configuration, without touching disk.
+func (h *ConfigHandler) UnmarshalJSON(data []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return json.Unmarshal(data, &h.cfg)
+}
+
+// UnmarshalYAML replaces the in-memory configuration, without touching disk.
+func (h *ConfigHandler) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return unmarshal(&h.cfg)
+}
+
+// DoLockedAction re-reads the config file and applies action to it, but only
+// if fingerprint still matches what's currently loaded. This lets the admin
+// /reload endpoint detect that the caller read a now-stale config (someone
+// else edited the file first) instead of silently clobbering their change:
+// a mismatch returns ErrFingerprintMismatch, which callers should surface as
+// 409 Conflict.
+func (h *ConfigHandler) DoLockedAction(fingerprint string, action func(cfg Config) error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if fingerprint != fingerprintOf(h.cfg) {
+		return ErrFingerprintMismatch
+	}
+
+	reloaded, err := parseFile(h.path)
+	if err != nil {
+		return err
+	}
+
+	if err := action(reloaded); err != nil {
+		return err
+	}
+
+	h.cfg = reloaded
+	return nil
+}
+
+// reload parses the file and replaces the in-memory config, without the
+// fingerprint check DoLockedAction does - used for the initial Load.
+func (h *ConfigHandler) reload() error {
+	cfg, err := parseFile(h.path)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	h.cfg = cfg
+	h.mu.Unlock()
+
+	return nil
+}
+
+// parseFile reads path and unmarshals it as YAML or JSON based on its extension.
+func parseFile(path string) (Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("unable to read config file: %v", err)
+	}
+
+	var cfg Config
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &cfg)
+	default:
+		err = json.Unmarshal(data, &cfg)
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("unable to parse config file: %v", err)
+	}
+
+	return cfg, nil
+}
+
+// fingerprintOf hashes the canonical JSON encoding of cfg.
+func fingerprintOf(cfg Config) string {
+	data, _ := json.Marshal(cfg)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
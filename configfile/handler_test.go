@@ -0,0 +1,138 @@
+package configfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestConfig(t *testing.T, dir, tenantId string) string {
+	t.Helper()
+	path := filepath.Join(dir, "config.json")
+	content := `{"tenant_id":"` + tenantId + `"}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("unable to write test config: %v", err)
+	}
+	return path
+}
+
+func TestDoLockedActionMatchingFingerprintApplies(t *testing.T) {
+	path := writeTestConfig(t, t.TempDir(), "first")
+	h, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	var applied Config
+	err = h.DoLockedAction(h.Fingerprint(), func(cfg Config) error {
+		applied = cfg
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DoLockedAction() with matching fingerprint failed: %v", err)
+	}
+	if applied.TenantId != "first" {
+		t.Errorf("action saw TenantId %q, want %q", applied.TenantId, "first")
+	}
+	if h.Config().TenantId != "first" {
+		t.Errorf("Config().TenantId = %q, want %q", h.Config().TenantId, "first")
+	}
+}
+
+func TestDoLockedActionMismatchedFingerprintReturnsError(t *testing.T) {
+	path := writeTestConfig(t, t.TempDir(), "first")
+	h, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	called := false
+	err = h.DoLockedAction("not-the-real-fingerprint", func(cfg Config) error {
+		called = true
+		return nil
+	})
+
+	if !errors.Is(err, ErrFingerprintMismatch) {
+		t.Errorf("DoLockedAction() with stale fingerprint = %v, want ErrFingerprintMismatch", err)
+	}
+	if called {
+		t.Error("action ran despite a fingerprint mismatch")
+	}
+}
+
+func TestDoLockedActionRereadsFileOnSuccess(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestConfig(t, dir, "first")
+	h, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	fingerprint := h.Fingerprint()
+	writeTestConfig(t, dir, "second")
+
+	var applied Config
+	err = h.DoLockedAction(fingerprint, func(cfg Config) error {
+		applied = cfg
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DoLockedAction() failed: %v", err)
+	}
+	if applied.TenantId != "second" {
+		t.Errorf("action saw TenantId %q, want %q (file was re-read)", applied.TenantId, "second")
+	}
+	if h.Config().TenantId != "second" {
+		t.Errorf("Config().TenantId = %q, want %q after DoLockedAction", h.Config().TenantId, "second")
+	}
+}
+
+func TestDoLockedActionErrorLeavesConfigUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestConfig(t, dir, "first")
+	h, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	fingerprint := h.Fingerprint()
+	writeTestConfig(t, dir, "second")
+
+	actionErr := errors.New("action rejected the reloaded config")
+	err = h.DoLockedAction(fingerprint, func(cfg Config) error {
+		return actionErr
+	})
+
+	if !errors.Is(err, actionErr) {
+		t.Fatalf("DoLockedAction() = %v, want %v", err, actionErr)
+	}
+	if h.Config().TenantId != "first" {
+		t.Errorf("Config().TenantId = %q, want %q (unchanged after a failed action)", h.Config().TenantId, "first")
+	}
+}
+
+func TestFingerprintChangesWithContent(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestConfig(t, dir, "first")
+	h, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	before := h.Fingerprint()
+	if err := h.DoLockedAction(before, func(cfg Config) error { return nil }); err != nil {
+		t.Fatalf("DoLockedAction() failed: %v", err)
+	}
+	if got := h.Fingerprint(); got != before {
+		t.Errorf("Fingerprint() changed to %q after a no-op reload of identical content, want unchanged %q", got, before)
+	}
+
+	writeTestConfig(t, dir, "second")
+	if err := h.DoLockedAction(before, func(cfg Config) error { return nil }); err != nil {
+		t.Fatalf("DoLockedAction() failed: %v", err)
+	}
+	if got := h.Fingerprint(); got == before {
+		t.Errorf("Fingerprint() unchanged after content actually changed")
+	}
+}
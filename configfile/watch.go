@@ -0,0 +1,48 @@
+package configfile
+
+import (
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+// Watch watches the config file for changes and, on every write, reloads it
+// and invokes onChange with the new configuration. It runs until the process
+// exits; errors reloading a changed file are logged and otherwise ignored, so
+// a momentarily half-written file doesn't take the watcher down.
+func (h *ConfigHandler) Watch(onChange func(cfg Config)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	// Watch the containing directory rather than the file itself: editors
+	// commonly replace a file (write-rename) rather than writing in place,
+	// which drops a direct watch on the old inode.
+	if err := watcher.Add(filepath.Dir(h.path)); err != nil {
+		_ = watcher.Close()
+		return err
+	}
+
+	go func() {
+		for event := range watcher.Events {
+			if filepath.Clean(event.Name) != filepath.Clean(h.path) {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+
+			if err := h.reload(); err != nil {
+				log.Errorf("config file reload failed: %v", err)
+				continue
+			}
+
+			log.Infof("config file %v changed, reloaded", h.path)
+			onChange(h.Config())
+		}
+	}()
+
+	return nil
+}
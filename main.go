@@ -1,34 +1,36 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"github.com/rfizzle/collector-helpers/outputs"
 	"github.com/rfizzle/collector-helpers/state"
+	"github.com/rfizzle/microsoft-graph-collector/admin"
 	"github.com/rfizzle/microsoft-graph-collector/client"
+	"github.com/rfizzle/microsoft-graph-collector/configfile"
+	"github.com/rfizzle/microsoft-graph-collector/subscription"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"io/ioutil"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 )
 
 func main() {
-	// Setup wait group for no closures
-	var wg sync.WaitGroup
-	wg.Add(1)
-
-	// Setup variables
-	var maxMessages = int64(5000)
-
 	// Setup logging
 	log.SetFormatter(&log.TextFormatter{
 		FullTimestamp: true,
 	})
 	log.SetOutput(os.Stdout)
 
-	// Setup Parameters via CLI or ENV
-	if err := setupCliFlags(); err != nil {
+	// Setup Parameters via CLI, ENV or --config file
+	configHandler, err := setupCliFlags()
+	if err != nil {
 		log.Errorf("initialization failed: %v", err.Error())
 		os.Exit(1)
 	}
@@ -40,148 +42,479 @@ func main() {
 		log.SetLevel(log.InfoLevel)
 	}
 
-	// Setup log writer
-	logger := &outputs.TmpWriter{}
+	// Soft close when CTRL + C is called
+	done := setupCloseHandler()
 
-	// Setup the channels for handling async messages
-	chnMessages := make(chan string, maxMessages)
+	// pipes hands each collector its own results channel and tmp-file writer,
+	// so concurrently-running collectors never contend for the same output
+	// state (see outputPipe).
+	pipes := newOutputPipes(done)
+
+	// Build the opt-in admin server before the Graph client so its Prometheus
+	// metrics can be threaded into the client's retry/auth logic. graphClient is
+	// assigned below but captured by reference here since authExpiry only runs
+	// once the server starts handling requests. reloadFn is likewise filled in
+	// once the runtime it restarts exists, but the server needs something to
+	// call from the moment it starts accepting requests.
+	var graphClient *client.GraphClient
+	var adminServer *admin.Server
+	var reloadFn func(fingerprint string) error
+	if addr := viper.GetString("admin-listen"); addr != "" {
+		adminServer = admin.NewServer(addr, viper.GetString("admin-token"), func() time.Time {
+			if graphClient == nil {
+				return time.Time{}
+			}
+			return graphClient.TokenExpiry()
+		}, func() string {
+			if configHandler == nil {
+				return ""
+			}
+			return configHandler.Fingerprint()
+		}, func(fingerprint string) error {
+			return reloadFn(fingerprint)
+		})
+	}
 
-	// Setup the Go Routine
-	pollTime := viper.GetInt("schedule")
+	var metrics client.Metrics
+	if adminServer != nil {
+		metrics = adminServer.Metrics()
+	}
 
-	// Soft close when CTRL + C is called
-	done := setupCloseHandler()
+	// Build a single Graph client to share across every collector. It logs in once
+	// and refreshes its own token proactively, rather than each poll logging in again.
+	graphClient, err = client.NewClient(viper.GetString("tenant-id"), viper.GetString("client-id"), buildAuthConfig(), buildRetryConfig(), metrics)
+	if err != nil {
+		log.Errorf("unable to build client: %v", err)
+		os.Exit(1)
+	}
 
 	// Let the user know the collector is starting
 	log.Infof("starting collector...")
 
-	// Start Poll
-	go pollEvery(pollTime, chnMessages, logger, done)
+	// In subscription mode, collectors that support change notifications are
+	// driven by Graph pushing to the webhook receiver instead of a ticker.
+	// subManager is nil in poll mode, and is shared across config reloads.
+	var subManager *subscription.Manager
+	if viper.GetString("mode") == "subscription" {
+		subManager = subscription.NewManager(graphClient, viper.GetString("notification-url"), func(collectorName, body string) {
+			pipes.get(collectorName).results <- body
+		}, collectorSubscriptionPath)
+
+		if addr := viper.GetString("webhook-listen"); addr != "" {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/notifications", subManager.HandleNotifications)
+			go func() {
+				if err := http.ListenAndServe(addr, mux); err != nil {
+					log.Errorf("webhook receiver stopped: %v", err)
+				}
+			}()
+		} else {
+			adminServer.RegisterHandler("/notifications", subManager.HandleNotifications)
+		}
+	}
+
+	// Start the admin server only now that every route on it, including a
+	// reused /notifications receiver, has been registered.
+	if adminServer != nil {
+		go func() {
+			if err := adminServer.Start(); err != nil {
+				log.Errorf("admin server stopped: %v", err)
+			}
+		}()
+	}
 
-	// Handle messages
-	go func() {
-		for {
-			message, ok := <-chnMessages
-			if !ok {
-				log.Debugf("closed channel, doing cleanup...")
-				cleanupProcedure(logger)
-				wg.Done()
-				return
-			} else {
-				handleMessage(message, logger)
+	// Give the receiver a moment to start listening before subscribing,
+	// since Graph validates --notification-url synchronously on creation.
+	if subManager != nil {
+		time.Sleep(time.Second)
+	}
+
+	// genMu guards stopGen: a config reload starts a fresh generation of
+	// collector goroutines reflecting the new config and then stops the one
+	// it replaces, so the two overlap briefly while the old one exits.
+	var genMu sync.Mutex
+	var stopGen func()
+
+	startGen := func(collectorNames []string) error {
+		collectors, err := resolveCollectors(collectorNames)
+		if err != nil {
+			return err
+		}
+
+		next := startGeneration(graphClient, collectors, configHandler, viper.GetInt("schedule"), adminServer, subManager, pipes, done)
+
+		genMu.Lock()
+		previous := stopGen
+		stopGen = next
+		genMu.Unlock()
+
+		if previous != nil {
+			previous()
+		}
+
+		return nil
+	}
+
+	if err := startGen(viper.GetStringSlice("collectors")); err != nil {
+		log.Errorf("initialization failed: %v", err.Error())
+		os.Exit(1)
+	}
+
+	// reloadFn backs both POST /reload and the config file watcher below: it
+	// re-validates (and, with a config file, re-reads and re-applies) the
+	// current configuration, rebuilding the running collector set to match.
+	reloadFn = func(fingerprint string) error {
+		if configHandler == nil {
+			if err := checkRequiredParams(); err != nil {
+				return err
+			}
+			return startGen(viper.GetStringSlice("collectors"))
+		}
+
+		err := configHandler.DoLockedAction(fingerprint, func(cfg configfile.Config) error {
+			applyConfigDefaults(cfg)
+			return checkRequiredParams()
+		})
+
+		if errors.Is(err, configfile.ErrFingerprintMismatch) {
+			return admin.ErrConflict
+		}
+		if err != nil {
+			return err
+		}
+
+		// startGen reads configHandler.Config(), which DoLockedAction keeps
+		// locked while its action runs, so the rebuild happens once it returns.
+		return startGen(viper.GetStringSlice("collectors"))
+	}
+
+	if configHandler != nil {
+		if err := configHandler.Watch(func(cfg configfile.Config) {
+			if err := reloadFn(configHandler.Fingerprint()); err != nil {
+				log.Errorf("unable to apply config file change: %v", err)
 			}
+		}); err != nil {
+			log.Errorf("unable to watch config file: %v", err)
+		}
+	}
+
+	// Stop whichever generation is current once the process is told to shut down
+	go func() {
+		<-done
+		genMu.Lock()
+		stop := stopGen
+		genMu.Unlock()
+		if stop != nil {
+			stop()
 		}
 	}()
 
-	wg.Wait()
+	// Block until every collector's pipe has seen done fire and finished
+	// flushing and cleaning up its tmp files.
+	pipes.Wait()
+	log.Infof("collector closed successfully...")
+}
+
+// startGeneration launches one poll (or subscription) goroutine per
+// collector and returns a stop function that halts them and waits for them
+// to exit. It's called once at startup and again after every successful
+// config reload. Every collector writes through
+// its own pipes.get(name) pipe, so concurrently-running collectors (current
+// and future generations alike) never contend for the same results channel
+// or tmp-file writer.
+func startGeneration(graphClient *client.GraphClient, collectors []client.Collector, configHandler *configfile.ConfigHandler, defaultSchedule int, adminServer *admin.Server, subManager *subscription.Manager, pipes *outputPipes, processDone chan bool) func() {
+	genDone := make(chan bool)
+	var stopOnce sync.Once
+	stop := func() {
+		stopOnce.Do(func() { close(genDone) })
+	}
+
+	// A process shutdown should also stop this generation, without leaking a
+	// goroutine per reload: it exits as soon as either channel fires.
+	go func() {
+		select {
+		case <-processDone:
+			stop()
+		case <-genDone:
+		}
+	}()
+
+	var wg sync.WaitGroup
+	handles := make(map[string]*admin.CollectorHandle, len(collectors))
+
+	for _, collector := range collectors {
+		wg.Add(1)
+
+		var handle *admin.CollectorHandle
+		if adminServer != nil {
+			handle = adminServer.RegisterCollector(collector.Name())
+		}
+		handles[collector.Name()] = handle
+
+		seconds := defaultSchedule
+		filter := ""
+		resourceMode := ""
+		if configHandler != nil {
+			if resource, ok := configHandler.Config().ResourceByName(collector.Name()); ok {
+				if resource.Schedule > 0 {
+					seconds = resource.Schedule
+				}
+				filter = resource.Filter
+				resourceMode = resource.Mode
+			}
+		}
+
+		// A resource's own mode may only narrow subscription down to poll for
+		// that one collector - it can't promote a collector into subscription
+		// mode on its own, since that also needs the process-wide
+		// --notification-url/--webhook-listen (validateResourceModes rejects
+		// the opposite case up front).
+		subscribed := subManager != nil && resourceMode != "poll" && subManager.Subscribe(collector, genDone)
+		pipe := pipes.get(collector.Name())
+
+		go func(c client.Collector, h *admin.CollectorHandle, seconds int, filter string) {
+			defer wg.Done()
+			if subscribed {
+				log.Infof("%v running in subscription mode", c.Name())
+				<-genDone
+				return
+			}
+			pollEvery(graphClient, c, h, seconds, filter, pipe, genDone)
+		}(collector, handle, seconds, filter)
+	}
+
+	if subManager != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			drainSubscriptionEvents(subManager, handles, pipes, defaultSchedule, genDone)
+		}()
+	}
+
+	return func() {
+		stop()
+		wg.Wait()
+	}
 }
 
-// Goroutine poll for collecting events
-func pollEvery(seconds int, resultsChannel chan<- string, logger *outputs.TmpWriter, done chan bool) {
+// Goroutine poll for collecting events from a single collector. handle is nil
+// unless the admin server is enabled, in which case it gates pausing/resuming
+// and out-of-band polling, and carries status back for GET /status.
+func pollEvery(graphClient *client.GraphClient, collector client.Collector, handle *admin.CollectorHandle, seconds int, filter string, pipe *outputPipe, done chan bool) {
 	var currentState *state.State
 	var err error
 
-	// Setup State
-	if state.Exists(viper.GetString("state-path")) {
-		currentState, err = state.Restore(viper.GetString("state-path"))
+	// Setup State (namespaced per collector so each resource tracks its own last-poll timestamp)
+	statePath := collectorStatePath(collector.Name())
+	if state.Exists(statePath) {
+		currentState, err = state.Restore(statePath)
 		if err != nil {
-			log.Errorf("error getting state: %v", err.Error())
+			log.Errorf("error getting state for %v: %v", collector.Name(), err.Error())
 			os.Exit(1)
 		}
 	} else {
 		currentState = state.New()
 	}
 
+	// Delta-capable collectors track a deltaLink alongside state rather than a
+	// last-poll timestamp
+	deltaPath := collectorDeltaPath(collector.Name())
+	deltaLink := ""
+	if collector.SupportsDelta() {
+		deltaLink, err = loadDeltaLink(deltaPath)
+		if err != nil {
+			log.Errorf("error getting delta link for %v: %v", collector.Name(), err.Error())
+			os.Exit(1)
+		}
+	}
+
+	paused := false
+
 	for {
+		// A paused collector still reacts to admin control signals, it just
+		// doesn't arm the next tick until resumed
+		var tick <-chan time.Time
+		if !paused {
+			tick = time.After(time.Duration(seconds) * time.Second)
+		}
+
 		select {
 		case <-done:
-			log.Debugf("closing go routine...")
-			close(resultsChannel)
+			log.Debugf("closing %v go routine...", collector.Name())
 			return
-		case <-time.After(time.Duration(seconds) * time.Second):
-			log.Infof("getting microsoft graph security events...")
+		case <-pauseCh(handle):
+			paused = true
+			handle.SetPaused(true)
+			log.Infof("%v collector paused via admin API", collector.Name())
+		case <-resumeCh(handle):
+			paused = false
+			handle.SetPaused(false)
+			log.Infof("%v collector resumed via admin API", collector.Name())
+		case <-forcePollCh(handle):
+			log.Infof("forcing %v poll via admin API...", collector.Name())
+			deltaLink = runCollectorPoll(graphClient, collector, handle, currentState, statePath, deltaLink, deltaPath, seconds, filter, pipe)
+		case <-tick:
+			log.Infof("getting microsoft graph %v events...", collector.Name())
+			deltaLink = runCollectorPoll(graphClient, collector, handle, currentState, statePath, deltaLink, deltaPath, seconds, filter, pipe)
+		}
+	}
+}
 
-			// Get events
-			eventCount, lastPollTime, err := getEvents(currentState.LastPollTimestamp, resultsChannel)
+// runCollectorPoll performs a single collection attempt (delta or time-window,
+// whichever the collector supports), flushes any collected events and
+// persists state, returning the (possibly updated) delta link. Shared by both
+// the regular ticker and an out-of-band /poll request.
+func runCollectorPoll(graphClient *client.GraphClient, collector client.Collector, handle *admin.CollectorHandle, currentState *state.State, statePath string, deltaLink string, deltaPath string, seconds int, filter string, pipe *outputPipe) string {
+	var eventCount int
+	var lastPollTime time.Time
+	var err error
 
-			// Handle error
-			if err != nil {
-				// Retry the request
-				continue
-			}
+	if collector.SupportsDelta() {
+		var newDeltaLink string
+		eventCount, newDeltaLink, err = getDeltaEvents(graphClient, collector, deltaLink, pipe.results)
+		lastPollTime = time.Now()
 
-			// Copy tmp file to correct outputs
-			if eventCount > 0 {
-				// Wait until the results channel has no more messages and all writes have completed
-				for len(resultsChannel) > 0 || logger.WriteCount != eventCount {
-					<-time.After(time.Duration(50) * time.Millisecond)
+		// Handle error
+		if err != nil {
+			// A 410 means the saved delta link expired - drop it so the next poll re-initializes
+			if client.IsDeltaExpired(err) {
+				log.Warnf("delta link expired for %v, reinitializing", collector.Name())
+				deltaLink = ""
+				if saveErr := saveDeltaLink(deltaPath, ""); saveErr != nil {
+					log.Errorf("unable to reset delta link for %v: %v", collector.Name(), saveErr)
 				}
+			}
+			recordPoll(handle, 0, lastPollTime, seconds, err)
+			return deltaLink
+		}
 
-				// Close and rotate file
-				err = logger.Rotate()
+		deltaLink = newDeltaLink
+		if err := saveDeltaLink(deltaPath, deltaLink); err != nil {
+			log.Errorf("unable to save delta link for %v: %v", collector.Name(), err)
+		}
+	} else {
+		// Get events
+		eventCount, lastPollTime, err = getEvents(graphClient, collector, currentState.LastPollTimestamp, filter, pipe.results)
 
-				// Handle error
-				if err != nil {
-					log.Errorf("unable to rotate file")
-					continue
-				}
+		// Handle error
+		if err != nil {
+			recordPoll(handle, 0, lastPollTime, seconds, err)
+			return deltaLink
+		}
+	}
 
-				// Get stats on source file
-				sourceFileStat, err := os.Stat(logger.PreviousFile().Name())
-				if err != nil {
-					log.Errorf("error reading last file path")
-					continue
-				}
+	// Copy tmp file to correct outputs
+	if eventCount > 0 {
+		err = flushCollectedEvents(pipe, eventCount, lastPollTime)
 
-				// Continue if source file size is 0 (technically this should never happen if there are events)
-				if sourceFileStat.Size() == 0 {
-					log.Errorf("tmp file is 0 bytes with events")
-					_ = logger.DeletePreviousFile()
-					continue
-				}
+		if err != nil {
+			log.Errorf("unable to flush %v events: %v", collector.Name(), err)
+			recordPoll(handle, 0, lastPollTime, seconds, err)
+			return deltaLink
+		}
+	}
 
-				// Write to enabled outputs
-				if err := outputs.WriteToOutputs(logger.PreviousFile().Name(), lastPollTime.Format(time.RFC3339)); err != nil {
-					log.Errorf("unable to write to output: %v", err)
-				}
+	// Let know that event has been processes
+	log.Infof("%v %v events processed", eventCount, collector.Name())
 
-				// Remove temp file now
-				err = logger.DeletePreviousFile()
-				if err != nil {
-					log.Errorf("unable to remove tmp file: %v", err)
-				}
-			}
+	// Update state (delta collectors persist their own link instead)
+	if !collector.SupportsDelta() {
+		currentState.LastPollTimestamp = lastPollTime.Format(time.RFC3339)
+		state.Save(currentState, statePath)
+	}
 
-			// Let know that event has been processes
-			log.Infof("%v events processed", eventCount)
+	recordPoll(handle, eventCount, lastPollTime, seconds, nil)
 
-			// Update state
-			currentState.LastPollTimestamp = lastPollTime.Format(time.RFC3339)
-			state.Save(currentState, viper.GetString("state-path"))
-		}
+	return deltaLink
+}
+
+// recordPoll reports a poll result to the admin server, if one is enabled
+func recordPoll(handle *admin.CollectorHandle, eventCount int, polledAt time.Time, seconds int, err error) {
+	if handle != nil {
+		handle.RecordPoll(eventCount, polledAt, polledAt.Add(time.Duration(seconds)*time.Second), err)
 	}
 }
 
-// Get events
-func getEvents(timestamp string, resultChannel chan<- string) (int, time.Time, error) {
-	// Get current time
-	now := time.Now()
+// pauseCh/resumeCh/forcePollCh return handle's control channel, or nil (which
+// blocks forever in a select) when the admin server is disabled
+func pauseCh(handle *admin.CollectorHandle) chan struct{} {
+	if handle == nil {
+		return nil
+	}
+	return handle.Pause
+}
+
+func resumeCh(handle *admin.CollectorHandle) chan struct{} {
+	if handle == nil {
+		return nil
+	}
+	return handle.Resume
+}
 
-	// Build an HTTP client with JWT header
-	graphClient, err := client.NewClient(viper.GetString("tenant-id"), viper.GetString("client-id"), viper.GetString("client-secret"))
+func forcePollCh(handle *admin.CollectorHandle) chan struct{} {
+	if handle == nil {
+		return nil
+	}
+	return handle.ForcePoll
+}
+
+// flushCollectedEvents waits for a poll's writes to land on pipe, rotates its
+// tmp file and hands it off to the configured outputs. pipe.mu keeps this from
+// racing a concurrent flush of the same pipe (e.g. a forced poll landing
+// while a notification-driven flush for the same collector is in flight).
+func flushCollectedEvents(pipe *outputPipe, eventCount int, lastPollTime time.Time) error {
+	pipe.mu.Lock()
+	defer pipe.mu.Unlock()
+
+	logger := pipe.logger
 
-	// Handle error
+	// Wait until the results channel has no more messages and all writes have completed
+	for len(pipe.results) > 0 || logger.WriteCount != eventCount {
+		<-time.After(time.Duration(50) * time.Millisecond)
+	}
+
+	// Close and rotate file
+	if err := logger.Rotate(); err != nil {
+		return fmt.Errorf("unable to rotate file: %v", err)
+	}
+
+	// Get stats on source file
+	sourceFileStat, err := os.Stat(logger.PreviousFile().Name())
 	if err != nil {
-		log.Errorf("unable to build client: %v", err)
-		return 0, now, err
+		return fmt.Errorf("error reading last file path: %v", err)
 	}
 
-	// Get alerts
-	dataCount, err := graphClient.GetAlerts(timestamp, now.Format(time.RFC3339), resultChannel)
+	// Bail if source file size is 0 (technically this should never happen if there are events)
+	if sourceFileStat.Size() == 0 {
+		log.Errorf("tmp file is 0 bytes with events")
+		_ = logger.DeletePreviousFile()
+		return nil
+	}
+
+	// Write to enabled outputs
+	if err := outputs.WriteToOutputs(logger.PreviousFile().Name(), lastPollTime.Format(time.RFC3339)); err != nil {
+		log.Errorf("unable to write to output: %v", err)
+	}
+
+	// Remove temp file now
+	if err := logger.DeletePreviousFile(); err != nil {
+		log.Errorf("unable to remove tmp file: %v", err)
+	}
+
+	return nil
+}
+
+// Get events for a single collector
+func getEvents(graphClient *client.GraphClient, collector client.Collector, timestamp string, filter string, resultChannel chan<- string) (int, time.Time, error) {
+	// Get current time
+	now := time.Now()
+
+	// Collect events
+	dataCount, err := collector.Collect(graphClient, timestamp, now.Format(time.RFC3339), filter, resultChannel)
 
 	// Return error
 	if err != nil {
-		log.Errorf("error getting alerts: %v", err)
+		log.Errorf("error getting %v events: %v", collector.Name(), err)
 		return 0, now, err
 	}
 
@@ -189,6 +522,109 @@ func getEvents(timestamp string, resultChannel chan<- string) (int, time.Time, e
 	return dataCount, now, nil
 }
 
+// Get delta events for a single collector
+func getDeltaEvents(graphClient *client.GraphClient, collector client.Collector, deltaLink string, resultChannel chan<- string) (int, string, error) {
+	// Collect delta events
+	dataCount, newDeltaLink, err := collector.CollectDelta(graphClient, deltaLink, resultChannel)
+
+	// Return error
+	if err != nil {
+		log.Errorf("error getting %v delta events: %v", collector.Name(), err)
+		return 0, deltaLink, err
+	}
+
+	// Return count and new delta link
+	return dataCount, newDeltaLink, nil
+}
+
+// resolveCollectors maps the configured collector names to their implementations
+func resolveCollectors(names []string) ([]client.Collector, error) {
+	available := client.AvailableCollectors()
+
+	var collectors []client.Collector
+	for _, name := range names {
+		collector, ok := available[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown collector %q (--collectors)", name)
+		}
+		collectors = append(collectors, collector)
+	}
+
+	if len(collectors) == 0 {
+		return nil, errors.New("no collectors enabled (--collectors)")
+	}
+
+	return collectors, nil
+}
+
+// collectorStatePath namespaces the shared state file per collector
+func collectorStatePath(name string) string {
+	return fmt.Sprintf("%s.%s", viper.GetString("state-path"), name)
+}
+
+// collectorDeltaPath namespaces a collector's saved @odata.deltaLink
+func collectorDeltaPath(name string) string {
+	return fmt.Sprintf("%s.%s.delta", viper.GetString("state-path"), name)
+}
+
+// collectorSubscriptionPath namespaces a collector's saved change-notification
+// subscription record
+func collectorSubscriptionPath(name string) string {
+	return fmt.Sprintf("%s.%s.subscription", viper.GetString("state-path"), name)
+}
+
+// drainSubscriptionEvents runs each notification result through the same
+// flush pipeline a poll result would use, so outputs/state don't need to know
+// whether an event arrived via polling or a webhook.
+func drainSubscriptionEvents(subManager *subscription.Manager, handles map[string]*admin.CollectorHandle, pipes *outputPipes, seconds int, done chan bool) {
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-subManager.Events():
+			if !ok {
+				return
+			}
+
+			handle := handles[event.CollectorName]
+
+			if event.Err != nil {
+				recordPoll(handle, 0, event.PolledAt, seconds, event.Err)
+				continue
+			}
+
+			var err error
+			if event.Count > 0 {
+				err = flushCollectedEvents(pipes.get(event.CollectorName), event.Count, event.PolledAt)
+				if err != nil {
+					log.Errorf("unable to flush %v notification event: %v", event.CollectorName, err)
+				}
+			}
+
+			recordPoll(handle, event.Count, event.PolledAt, seconds, err)
+		}
+	}
+}
+
+// loadDeltaLink reads a collector's saved delta link, returning an empty
+// string (not an error) if one hasn't been saved yet
+func loadDeltaLink(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// saveDeltaLink persists a collector's delta link for the next run
+func saveDeltaLink(path, link string) error {
+	return ioutil.WriteFile(path, []byte(link), 0644)
+}
+
 // Handle message in a channel
 func handleMessage(message string, logger *outputs.TmpWriter) {
 	if _, err := logger.WriteString(message); err != nil {
@@ -200,24 +636,12 @@ func handleMessage(message string, logger *outputs.TmpWriter) {
 // program if it receives an interrupt from the OS.
 func setupCloseHandler() chan bool {
 	done := make(chan bool)
-	c := make(chan os.Signal)
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
-		done <- true
+		close(done)
 	}()
 
 	return done
 }
-
-// Cleanup collector tmp files
-func cleanupProcedure(w *outputs.TmpWriter) {
-	// Remove last temp file
-	log.Debugf("removing temp files...")
-	if err := w.Exit(); err != nil {
-		log.Errorf("unable to close tmp writer successfully: %v", err)
-	}
-
-	// Close message
-	log.Infof("collector closed successfully...")
-}
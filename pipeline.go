@@ -0,0 +1,98 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/rfizzle/collector-helpers/outputs"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxPipeMessages bounds how many collected-but-not-yet-written events an
+// individual collector's pipe can buffer before it blocks its producer.
+const maxPipeMessages = 5000
+
+// outputPipe is a single collector's private results channel and tmp-file
+// writer. Every collector gets its own pipe rather than sharing one channel
+// and one outputs.TmpWriter across all of them: flushCollectedEvents relies
+// on logger.WriteCount reaching the poll's eventCount to know every write has
+// landed, and that tally (and the tmp file behind it) would otherwise be
+// shared by every concurrently-running collector.
+type outputPipe struct {
+	results chan string
+	logger  *outputs.TmpWriter
+
+	mu sync.Mutex
+}
+
+func newOutputPipe() *outputPipe {
+	return &outputPipe{
+		results: make(chan string, maxPipeMessages),
+		logger:  &outputs.TmpWriter{},
+	}
+}
+
+// outputPipes hands out one outputPipe per collector name, creating it (and
+// its draining goroutine) the first time that name is seen. Pipes outlive
+// any single generation so a config reload that restarts collectors doesn't
+// lose buffered-but-unwritten events.
+type outputPipes struct {
+	done <-chan bool
+
+	mu     sync.Mutex
+	byName map[string]*outputPipe
+	wg     sync.WaitGroup
+}
+
+func newOutputPipes(done <-chan bool) *outputPipes {
+	return &outputPipes{done: done, byName: make(map[string]*outputPipe)}
+}
+
+// get returns name's pipe, creating it and starting its drain goroutine on
+// first use.
+func (p *outputPipes) get(name string) *outputPipe {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pipe, ok := p.byName[name]
+	if !ok {
+		pipe = newOutputPipe()
+		p.byName[name] = pipe
+		p.wg.Add(1)
+		go p.drain(name, pipe)
+	}
+
+	return pipe
+}
+
+// drain writes pipe's collected messages to its own tmp file until the
+// process is told to shut down, then flushes whatever's still buffered and
+// removes its tmp files.
+func (p *outputPipes) drain(name string, pipe *outputPipe) {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case message := <-pipe.results:
+			handleMessage(message, pipe.logger)
+		case <-p.done:
+			// Drain whatever's already buffered before cleaning up.
+			for {
+				select {
+				case message := <-pipe.results:
+					handleMessage(message, pipe.logger)
+				default:
+					if err := pipe.logger.Exit(); err != nil {
+						log.Errorf("unable to close tmp writer for %v successfully: %v", name, err)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// Wait blocks until every pipe has seen done fire and finished flushing and
+// cleaning up.
+func (p *outputPipes) Wait() {
+	p.wg.Wait()
+}
@@ -0,0 +1,219 @@
+package subscription
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rfizzle/microsoft-graph-collector/client"
+	log "github.com/sirupsen/logrus"
+)
+
+// renewalSafetyMargin is how far ahead of a subscription's maximum lifetime
+// the renewal goroutine PATCHes its expiration forward, so a slow renewal
+// never races Graph expiring it first.
+const renewalSafetyMargin = 10 * time.Minute
+
+// eventBuffer bounds how many pending notification results can be queued for
+// the flush drain loop before HandleNotifications blocks.
+const eventBuffer = 64
+
+// renewalRetryBackoff is how soon renewLoop tries again after a failed
+// renewal, rather than waiting out a full subscription lifetime against an
+// expiration that was never actually applied on Graph's side.
+const renewalRetryBackoff = time.Minute
+
+// tracked is a subscription this Manager is maintaining: the data needed to
+// verify incoming notifications and to renew it before it lapses.
+type tracked struct {
+	collectorName string
+	clientState   string
+	sidecarPath   string
+}
+
+// NotificationEvent reports that a change notification was turned into a
+// resource fetch for collectorName, so the caller can run it through the same
+// flush/state pipeline a poll result would use. Count is how many flattened
+// events the fetch produced (usually one, but the notified resource can
+// itself be a collection).
+type NotificationEvent struct {
+	CollectorName string
+	PolledAt      time.Time
+	Count         int
+	Err           error
+}
+
+// Manager creates, renews and reconciles Graph change-notification
+// subscriptions, and turns incoming notifications into targeted resource GETs
+// handed off via sendResult.
+type Manager struct {
+	graphClient     *client.GraphClient
+	notificationUrl string
+	sendResult      func(collectorName, body string)
+	sidecarPath     func(collectorName string) string
+	events          chan NotificationEvent
+
+	mu   sync.RWMutex
+	byID map[string]*tracked
+}
+
+// NewManager builds a subscription Manager. notificationUrl must be the
+// public HTTPS URL Graph can reach to deliver notifications and perform the
+// validation handshake. sendResult is called with the fetched resource body
+// for each notification, and must route it to that collector's own results
+// pipe - never a pipe shared with any other collector. sidecarPath returns
+// where a collector's subscription record is persisted, analogous to the
+// delta link sidecar files.
+func NewManager(graphClient *client.GraphClient, notificationUrl string, sendResult func(collectorName, body string), sidecarPath func(string) string) *Manager {
+	return &Manager{
+		graphClient:     graphClient,
+		notificationUrl: notificationUrl,
+		sendResult:      sendResult,
+		sidecarPath:     sidecarPath,
+		events:          make(chan NotificationEvent, eventBuffer),
+		byID:            make(map[string]*tracked),
+	}
+}
+
+// Events returns the channel of flush-worthy results produced by incoming
+// notifications. The caller is expected to drain it for the life of the process.
+func (m *Manager) Events() <-chan NotificationEvent {
+	return m.events
+}
+
+// Subscribe reconciles collector's saved subscription (renewing or
+// recreating it as needed) and, on success, starts its renewal goroutine. It
+// returns false if the collector doesn't support subscriptions or Graph
+// rejected the notification URL - callers should fall back to polling it.
+func (m *Manager) Subscribe(collector client.Collector, done <-chan bool) bool {
+	resource, changeType, maxLifetime, ok := collector.SubscriptionResource()
+	if !ok {
+		return false
+	}
+
+	path := m.sidecarPath(collector.Name())
+	rec, err := loadRecord(path)
+	if err != nil {
+		log.Warnf("unable to load subscription record for %v, recreating: %v", collector.Name(), err)
+		rec = record{}
+	}
+
+	clientState := rec.ClientState
+	if clientState == "" {
+		clientState, err = newClientState()
+		if err != nil {
+			log.Errorf("unable to generate client state for %v: %v", collector.Name(), err)
+			return false
+		}
+	}
+
+	rec, err = m.reconcile(collector.Name(), resource, changeType, clientState, maxLifetime, rec)
+	if err != nil {
+		log.Warnf("unable to establish %v subscription, falling back to poll mode: %v", collector.Name(), err)
+		return false
+	}
+
+	if err := saveRecord(path, rec); err != nil {
+		log.Errorf("unable to save subscription record for %v: %v", collector.Name(), err)
+	}
+
+	m.mu.Lock()
+	m.byID[rec.SubscriptionID] = &tracked{
+		collectorName: collector.Name(),
+		clientState:   clientState,
+		sidecarPath:   path,
+	}
+	m.mu.Unlock()
+
+	go m.renewLoop(rec.SubscriptionID, rec.ExpirationDateTime, maxLifetime, done)
+
+	log.Infof("subscribed %v to change notifications (id=%v, expires=%v)", collector.Name(), rec.SubscriptionID, rec.ExpirationDateTime.Format(time.RFC3339))
+
+	return true
+}
+
+// reconcile verifies an existing subscription is still registered with
+// Graph and renews it, or creates a new one if it's missing or this is the
+// first run.
+func (m *Manager) reconcile(name, resource, changeType, clientState string, maxLifetime time.Duration, rec record) (record, error) {
+	expiration := time.Now().Add(maxLifetime - renewalSafetyMargin)
+
+	if rec.SubscriptionID != "" {
+		if _, err := m.graphClient.GetSubscription(rec.SubscriptionID); err == nil {
+			if err := m.graphClient.RenewSubscription(rec.SubscriptionID, expiration); err == nil {
+				rec.ExpirationDateTime = expiration
+				return rec, nil
+			}
+		}
+		log.Infof("subscription for %v no longer valid, recreating", name)
+	}
+
+	sub, err := m.graphClient.CreateSubscription(resource, changeType, m.notificationUrl, clientState, expiration)
+	if err != nil {
+		return record{}, err
+	}
+
+	return record{
+		SubscriptionID:     sub.ID,
+		ClientState:        clientState,
+		ExpirationDateTime: expiration,
+	}, nil
+}
+
+// renewLoop PATCHes the subscription's expiration forward before it lapses,
+// persisting the new expiration, until done fires. expiration tracks what's
+// actually been confirmed with Graph - it only advances on a successful
+// renewal, so a failed attempt retries soon instead of sleeping out a whole
+// new lifetime against an expiration Graph never agreed to.
+func (m *Manager) renewLoop(id string, expiration time.Time, maxLifetime time.Duration, done <-chan bool) {
+	for {
+		sleep := time.Until(expiration) - renewalSafetyMargin
+		if sleep < 0 {
+			sleep = 0
+		}
+
+		select {
+		case <-done:
+			return
+		case <-time.After(sleep):
+			target := time.Now().Add(maxLifetime - renewalSafetyMargin)
+			if err := m.graphClient.RenewSubscription(id, target); err != nil {
+				log.Errorf("unable to renew subscription %v, retrying in %v: %v", id, renewalRetryBackoff, err)
+				select {
+				case <-done:
+					return
+				case <-time.After(renewalRetryBackoff):
+				}
+				continue
+			}
+			expiration = target
+
+			m.mu.RLock()
+			t, ok := m.byID[id]
+			m.mu.RUnlock()
+
+			if ok {
+				rec := record{SubscriptionID: id, ClientState: t.clientState, ExpirationDateTime: expiration}
+				if err := saveRecord(t.sidecarPath, rec); err != nil {
+					log.Errorf("unable to save renewed subscription record for %v: %v", t.collectorName, err)
+				}
+			}
+
+			log.Debugf("renewed subscription %v, new expiration %v", id, expiration.Format(time.RFC3339))
+		}
+	}
+}
+
+// newClientState generates the shared secret Graph echoes back on every
+// notification so HandleNotifications can verify it didn't come from
+// somewhere else.
+func newClientState() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("unable to generate client state: %v", err)
+	}
+
+	return hex.EncodeToString(buf), nil
+}
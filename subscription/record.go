@@ -0,0 +1,45 @@
+package subscription
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// record is the sidecar state persisted per collector, mirroring the
+// deltaLink sidecar files main.go already keeps next to the state path.
+type record struct {
+	SubscriptionID     string    `json:"subscription_id"`
+	ClientState        string    `json:"client_state"`
+	ExpirationDateTime time.Time `json:"expiration_date_time"`
+}
+
+// loadRecord reads a collector's saved subscription record, returning a zero
+// record (not an error) if one hasn't been saved yet.
+func loadRecord(path string) (record, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return record{}, nil
+	}
+	if err != nil {
+		return record{}, err
+	}
+
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return record{}, err
+	}
+
+	return rec, nil
+}
+
+// saveRecord persists a collector's subscription record for the next run.
+func saveRecord(path string, rec record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
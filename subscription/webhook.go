@@ -0,0 +1,98 @@
+package subscription
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/rfizzle/microsoft-graph-collector/client"
+	log "github.com/sirupsen/logrus"
+)
+
+// notificationEnvelope is the body Graph POSTs for a batch of change
+// notifications.
+type notificationEnvelope struct {
+	Value []notificationPayload `json:"value"`
+}
+
+type notificationPayload struct {
+	SubscriptionId string `json:"subscriptionId"`
+	ClientState    string `json:"clientState"`
+	Resource       string `json:"resource"`
+}
+
+// HandleNotifications serves both Graph's subscription validation handshake
+// and its change notification deliveries. Register it wherever
+// --notification-url points, whether that's the admin server or a dedicated
+// --webhook-listen port.
+func (m *Manager) HandleNotifications(w http.ResponseWriter, r *http.Request) {
+	// Validation handshake: Graph calls back with ?validationToken=... when a
+	// subscription is created or renewed and expects it echoed as text/plain
+	// within 10 seconds.
+	if token := r.URL.Query().Get("validationToken"); token != "" {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(token))
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "unable to read body", http.StatusBadRequest)
+		return
+	}
+
+	var envelope notificationEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		http.Error(w, "invalid notification payload", http.StatusBadRequest)
+		return
+	}
+
+	// Acknowledge immediately - Graph retries (and eventually drops the
+	// subscription) on a slow or missing response, and the resource fetches
+	// below can take longer than that's worth waiting on.
+	w.WriteHeader(http.StatusAccepted)
+
+	go func() {
+		for _, notification := range envelope.Value {
+			m.handleNotification(notification)
+		}
+	}()
+}
+
+// handleNotification verifies clientState, fetches the changed resource and
+// reports the result on m.Events() so the caller can flush it through the
+// same pipeline a poll result would use.
+func (m *Manager) handleNotification(notification notificationPayload) {
+	m.mu.RLock()
+	t, ok := m.byID[notification.SubscriptionId]
+	m.mu.RUnlock()
+
+	if !ok {
+		log.Warnf("notification for unknown subscription %v, ignoring", notification.SubscriptionId)
+		return
+	}
+
+	if notification.ClientState != t.clientState {
+		log.Warnf("notification for %v failed clientState verification, ignoring", t.collectorName)
+		return
+	}
+
+	body, err := m.graphClient.GetResource(notification.Resource)
+	if err != nil {
+		log.Errorf("unable to fetch notified resource for %v: %v", t.collectorName, err)
+		m.events <- NotificationEvent{CollectorName: t.collectorName, PolledAt: time.Now(), Err: err}
+		return
+	}
+
+	// Flatten the same way collectByTimeWindow/GetDelta do, so a notified
+	// resource lands in the output pipeline as one compact JSON object per
+	// line rather than a possibly pretty-printed, possibly paged-envelope body.
+	events := client.FlattenResource(body)
+	for _, event := range events {
+		m.sendResult(t.collectorName, event)
+	}
+
+	m.events <- NotificationEvent{CollectorName: t.collectorName, PolledAt: time.Now(), Count: len(events)}
+}